@@ -0,0 +1,309 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// C14N returns the inclusive canonical form of the xml document b,
+// as defined by Canonical XML 1.0 without comments
+func C14N(b []byte) ([]byte, error) {
+	c := &canonicalizer{}
+	return c.canonicalize(b, nil)
+}
+
+// ExcC14N returns the exclusive canonical form of the xml document b, as defined by
+// Exclusive XML Canonicalization 1.0 without comments. prefixes is the InclusiveNamespaces
+// PrefixList, handled the inclusive way, #default stands for the default namespace
+func ExcC14N(b []byte, prefixes ...string) ([]byte, error) {
+	c := &canonicalizer{exclusive: true, inclusive: map[string]bool{}}
+	for _, p := range prefixes {
+		if p == "#default" {
+			p = ""
+		}
+		c.inclusive[p] = true
+	}
+
+	return c.canonicalize(b, nil)
+}
+
+type canonicalizer struct {
+	exclusive bool
+	inclusive map[string]bool
+}
+
+// c14nFrame is the state of an open element
+type c14nFrame struct {
+	// scope maps the prefixes in scope to their namespace
+	scope map[string]string
+	// rendered maps the prefixes declared by the output ancestors to their namespace
+	rendered map[string]string
+	output   bool
+	name     string
+}
+
+// apexFunc reports whether the raw start element s, at the byte offset of b, is the
+// apex of the canonicalized subtree. scope maps the prefixes in scope to their namespace
+type apexFunc func(s xml.StartElement, scope map[string]string, offset int) bool
+
+// canonicalize writes the canonical form of the subtree of the first element matched
+// by apex, or of the whole document if apex is nil. Namespaces declared by the
+// ancestors of apex are taken into account
+func (c *canonicalizer) canonicalize(b []byte, apex apexFunc) ([]byte, error) {
+	var (
+		out   bytes.Buffer
+		stack []*c14nFrame
+		done  bool
+		root  bool
+	)
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = true
+
+	for !done {
+		offset := int(d.InputOffset())
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		parent := &c14nFrame{scope: map[string]string{"xml": xmlNamespace}, rendered: map[string]string{}, output: apex == nil}
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			f := &c14nFrame{scope: parent.scope, rendered: parent.rendered, output: parent.output, name: qualifiedName(t.Name)}
+
+			decls := map[string]string{}
+			for _, a := range t.Attr {
+				if p, ok := namespaceDecl(a.Name); ok {
+					decls[p] = a.Value
+				}
+			}
+
+			if len(decls) > 0 {
+				f.scope = copyScope(parent.scope)
+				for p, ns := range decls {
+					f.scope[p] = ns
+				}
+			}
+
+			if !f.output && apex(t, f.scope, offset) {
+				f.output = true
+				parent = &c14nFrame{rendered: map[string]string{}}
+				f.rendered = parent.rendered
+			}
+
+			root = true
+
+			if f.output {
+				if err := c.startElement(&out, t, f, parent); err != nil {
+					return nil, err
+				}
+			}
+
+			stack = append(stack, f)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected end element %s", qualifiedName(t.Name))
+			}
+
+			f := stack[len(stack)-1]
+			if f.name != qualifiedName(t.Name) {
+				return nil, fmt.Errorf("element %s closed by %s", f.name, qualifiedName(t.Name))
+			}
+
+			stack = stack[:len(stack)-1]
+			if f.output {
+				fmt.Fprintf(&out, "</%s>", f.name)
+
+				// the apex is closed
+				if apex != nil && (len(stack) == 0 || !stack[len(stack)-1].output) {
+					done = true
+				}
+			}
+		case xml.CharData:
+			if len(stack) > 0 && parent.output {
+				out.WriteString(escapeC14NText(string(t)))
+			}
+		case xml.ProcInst:
+			if t.Target == "xml" || !parent.output {
+				continue
+			}
+
+			// processing instructions around the document element are separated from it by a line feed
+			if len(stack) == 0 && root {
+				out.WriteByte('\n')
+			}
+
+			out.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				out.WriteString(" " + string(t.Inst))
+			}
+			out.WriteString("?>")
+
+			if len(stack) == 0 && !root {
+				out.WriteByte('\n')
+			}
+		}
+	}
+
+	if apex != nil && out.Len() == 0 {
+		return nil, fmt.Errorf("element to canonicalize not found")
+	}
+
+	return out.Bytes(), nil
+}
+
+func (c *canonicalizer) startElement(out *bytes.Buffer, t xml.StartElement, f, parent *c14nFrame) error {
+	var (
+		nsDecls []xml.Attr
+		attrs   []xml.Attr
+		copied  bool
+	)
+
+	render := func(p string) {
+		ns, ok := f.scope[p]
+		if p == "xml" || (!ok && p != "") {
+			return
+		}
+
+		if cur, ok := f.rendered[p]; ok && cur == ns {
+			return
+		}
+
+		// an empty default namespace is only declared to undo a rendered one
+		if p == "" && ns == "" && f.rendered[""] == "" {
+			return
+		}
+
+		if !copied {
+			f.rendered = copyScope(parent.rendered)
+			copied = true
+		}
+		f.rendered[p] = ns
+
+		name := xml.Name{Local: "xmlns"}
+		if p != "" {
+			name = xml.Name{Space: "xmlns", Local: p}
+		}
+		nsDecls = append(nsDecls, xml.Attr{Name: name, Value: ns})
+	}
+
+	if c.exclusive {
+		used := map[string]bool{t.Name.Space: true}
+		for _, a := range t.Attr {
+			if _, ok := namespaceDecl(a.Name); !ok && a.Name.Space != "" {
+				used[a.Name.Space] = true
+			}
+		}
+		for p := range c.inclusive {
+			if _, ok := f.scope[p]; ok || p == "" {
+				used[p] = true
+			}
+		}
+
+		for p := range used {
+			if _, ok := f.scope[p]; !ok && p != "" {
+				return fmt.Errorf("prefix %q of element %s not declared", p, qualifiedName(t.Name))
+			}
+			render(p)
+		}
+	} else {
+		for p := range f.scope {
+			render(p)
+		}
+		if _, ok := f.scope[""]; !ok {
+			render("")
+		}
+	}
+
+	for _, a := range t.Attr {
+		if _, ok := namespaceDecl(a.Name); !ok {
+			attrs = append(attrs, a)
+		}
+	}
+
+	sort.Slice(nsDecls, func(i, j int) bool {
+		return nsDecls[i].Name.Local == "xmlns" || (nsDecls[j].Name.Local != "xmlns" && nsDecls[i].Name.Local < nsDecls[j].Name.Local)
+	})
+
+	sort.Slice(attrs, func(i, j int) bool {
+		ni, nj := attrNamespace(attrs[i].Name, f.scope), attrNamespace(attrs[j].Name, f.scope)
+		if ni != nj {
+			return ni < nj
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+
+	out.WriteString("<" + f.name)
+	for _, a := range append(nsDecls, attrs...) {
+		fmt.Fprintf(out, ` %s="%s"`, qualifiedName(a.Name), escapeC14NAttr(a.Value))
+	}
+	out.WriteString(">")
+
+	return nil
+}
+
+// namespaceDecl reports whether n is the name of a namespace declaration and its prefix
+func namespaceDecl(n xml.Name) (string, bool) {
+	if n.Space == "" && n.Local == "xmlns" {
+		return "", true
+	}
+
+	if n.Space == "xmlns" {
+		return n.Local, true
+	}
+
+	return "", false
+}
+
+// attrNamespace resolves the namespace of an attribute, unprefixed ones have none
+func attrNamespace(n xml.Name, scope map[string]string) string {
+	if n.Space == "" {
+		return ""
+	}
+
+	return scope[n.Space]
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return n.Space + ":" + n.Local
+}
+
+func copyScope(s map[string]string) map[string]string {
+	c := make(map[string]string, len(s)+1)
+	for k, v := range s {
+		c[k] = v
+	}
+
+	return c
+}
+
+var (
+	c14nTextReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	c14nAttrReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeC14NText(s string) string {
+	return c14nTextReplacer.Replace(s)
+}
+
+func escapeC14NAttr(s string) string {
+	return c14nAttrReplacer.Replace(s)
+}
@@ -0,0 +1,103 @@
+package gosoap
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestC14N(t *testing.T) {
+	in := `<?xml version="1.0"?>
+<?xml-stylesheet href="doc.xsl" type="text/xsl"   ?>
+<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <!-- comment -->
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+   <text a="tab	&amp; &quot;q&quot;"><![CDATA[x < y & z > w]]></text>
+</doc>`
+
+	want := `<?xml-stylesheet href="doc.xsl" type="text/xsl"   ?>
+<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org"></e9>
+         </e8>
+      </e7>
+   </e6>
+   <text a="tab&#x9;&amp; &quot;q&quot;">x &lt; y &amp; z &gt; w</text>
+</doc>`
+
+	got, err := C14N([]byte(in))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := C14N([]byte(`<a><b></a>`)); err == nil {
+		t.Errorf("error expected for malformed xml")
+	}
+}
+
+func TestExcC14N(t *testing.T) {
+	in := `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"/></n1:elem2></n0:local>`
+	elem2 := func(s xml.StartElement, _ map[string]string, _ int) bool {
+		return s.Name.Local == "elem2"
+	}
+
+	tests := []struct {
+		c    *canonicalizer
+		want string
+	}{
+		{
+			c:    &canonicalizer{},
+			want: `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xmlns:n3="ftp://example.org" xml:lang="en"><n3:stuff></n3:stuff></n1:elem2>`,
+		},
+		{
+			c:    &canonicalizer{exclusive: true},
+			want: `<n1:elem2 xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`,
+		},
+		{
+			c:    &canonicalizer{exclusive: true, inclusive: map[string]bool{"n0": true}},
+			want: `<n1:elem2 xmlns:n0="foo:bar" xmlns:n1="http://example.net" xml:lang="en"><n3:stuff xmlns:n3="ftp://example.org"></n3:stuff></n1:elem2>`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.c.canonicalize([]byte(in), elem2)
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+
+		if string(got) != tt.want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+		}
+	}
+
+	got, err := ExcC14N([]byte(`<a:Envelope xmlns:a="urn:a" xmlns:unused="urn:u"><Body xmlns="urn:b"><c xmlns=""/></Body></a:Envelope>`))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if want := `<a:Envelope xmlns:a="urn:a"><Body xmlns="urn:b"><c xmlns=""></c></Body></a:Envelope>`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,345 @@
+// Command gosoap inspects WSDL documents and invokes their operations.
+//
+// Usage:
+//
+//	gosoap services [flags] <wsdl>
+//	gosoap operations [flags] <wsdl>
+//	gosoap describe [flags] <wsdl> [operation]
+//	gosoap sample [-format json|xml] [flags] <wsdl> <operation>
+//	gosoap call [flags] <wsdl> <operation>
+//	gosoap lint [flags] <wsdl>
+//
+// Every command takes -username and -password for the basic auth of the wsdl and
+// soap requests, and -timeout for each http request.
+//
+// Params of call are given as repeated -p name=value flags, where dotted names
+// build nested elements (-p address.city=Lisbon), or as a JSON or YAML document
+// with -json and -yaml. A value starting with @ is read from the named file.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tiaguinho/gosoap"
+)
+
+const usage = `usage: gosoap <command> [flags] <wsdl> [operation]
+
+commands:
+  services    list the services and ports of the wsdl
+  operations  list the operations of the default port
+  describe    show the input and output schema of the operations
+  sample      print skeleton params or a sample envelope for an operation
+  call        invoke an operation and print the response
+  lint        report the problems of the wsdl, with their line
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "services":
+		err = services(args[1:], stdout, stderr)
+	case "operations":
+		err = operations(args[1:], stdout, stderr)
+	case "describe":
+		err = describe(args[1:], stdout, stderr)
+	case "sample":
+		err = sample(args[1:], stdout, stderr)
+	case "call":
+		err = call(args[1:], stdout, stderr)
+	case "lint":
+		err = lint(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "gosoap: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "gosoap: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func services(args []string, w, stderr io.Writer) error {
+	fs := flag.NewFlagSet("services", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	fs.SetOutput(stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("services expects the wsdl as only argument")
+	}
+
+	c, err := loadClient(fs.Arg(0), *cf.username, *cf.password, *cf.timeout)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range c.Definitions.Services {
+		fmt.Fprintln(w, s.Name)
+		for _, p := range s.Ports {
+			location := ""
+			if len(p.SoapAddresses) > 0 {
+				location = p.SoapAddresses[0].Location
+			}
+			fmt.Fprintf(w, "  %s (binding %s) %s\n", p.Name, p.Binding, location)
+		}
+	}
+
+	return nil
+}
+
+func operations(args []string, w, stderr io.Writer) error {
+	fs := flag.NewFlagSet("operations", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	fs.SetOutput(stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("operations expects the wsdl as only argument")
+	}
+
+	c, err := loadClient(fs.Arg(0), *cf.username, *cf.password, *cf.timeout)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range c.Definitions.OperationNames() {
+		fmt.Fprintln(w, o)
+	}
+
+	return nil
+}
+
+func describe(args []string, w, stderr io.Writer) error {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	fs.SetOutput(stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return fmt.Errorf("describe expects the wsdl and optionally an operation")
+	}
+
+	c, err := loadClient(fs.Arg(0), *cf.username, *cf.password, *cf.timeout)
+	if err != nil {
+		return err
+	}
+
+	ops := c.Definitions.OperationNames()
+	if fs.NArg() == 2 {
+		ops = []string{fs.Arg(1)}
+	}
+
+	for i, o := range ops {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		in, err := c.Definitions.InputSchema(o)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "%s\n  input:\n", o)
+		printTree(w, in, "    ")
+
+		if out, err := c.Definitions.OutputSchema(o); err == nil {
+			fmt.Fprintln(w, "  output:")
+			printTree(w, out, "    ")
+		}
+	}
+
+	return nil
+}
+
+func sample(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("sample", flag.ContinueOnError)
+	format := fs.String("format", "json", "json prints params usable with call -json, xml prints the envelope")
+	cf := addClientFlags(fs)
+	fs.SetOutput(stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("sample expects the wsdl and the operation")
+	}
+
+	c, err := loadClient(fs.Arg(0), *cf.username, *cf.password, *cf.timeout)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		p, err := c.Definitions.SampleParams(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		e := json.NewEncoder(stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(p)
+	case "xml":
+		b, err := c.SampleRequest(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(stdout, "%s\n", b)
+		return err
+	}
+
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+func call(args []string, stdout, stderr io.Writer) error {
+	var (
+		fs      = flag.NewFlagSet("call", flag.ContinueOnError)
+		params  paramFlags
+		jsonDoc = fs.String("json", "", "params as JSON document, @file reads it from file")
+		yamlDoc = fs.String("yaml", "", "params as YAML document, @file reads it from file")
+		format  = fs.String("format", "xml", "output format of the response body: xml or json")
+		cf      = addClientFlags(fs)
+	)
+	fs.Var(&params, "p", "param as name=value, may be repeated")
+	fs.SetOutput(stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		return fmt.Errorf("call expects the wsdl and the operation")
+	}
+
+	if *format != "xml" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	p, err := buildParams(params, *jsonDoc, *yamlDoc)
+	if err != nil {
+		return err
+	}
+
+	c, err := loadClient(fs.Arg(0), *cf.username, *cf.password, *cf.timeout)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.Call(fs.Arg(1), p)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		return writeJSON(stdout, c, fs.Arg(1), res.Body)
+	}
+
+	return writeXML(stdout, res.Body)
+}
+
+func lint(args []string, w, stderr io.Writer) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	cf := addClientFlags(fs)
+	fs.SetOutput(stderr)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("lint expects the wsdl as only argument")
+	}
+
+	// the definitions aren't loaded, the document may not be usable
+	c, err := newClient(fs.Arg(0), *cf.username, *cf.password, *cf.timeout)
+	if err != nil {
+		return err
+	}
+
+	warnings, err := c.Lint()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range warnings {
+		fmt.Fprintln(w, l)
+	}
+
+	if len(warnings) > 0 {
+		return fmt.Errorf("%d warnings", len(warnings))
+	}
+
+	return nil
+}
+
+// clientFlags are the flags of the commands fetching the wsdl with credentials
+type clientFlags struct {
+	username, password *string
+	timeout            *time.Duration
+}
+
+func addClientFlags(fs *flag.FlagSet) clientFlags {
+	return clientFlags{
+		username: fs.String("username", "", "basic auth username"),
+		password: fs.String("password", "", "basic auth password"),
+		timeout:  fs.Duration("timeout", 30*time.Second, "timeout of each http request"),
+	}
+}
+
+func newClient(wsdl, username, password string, timeout time.Duration) (*gosoap.Client, error) {
+	c, err := gosoap.SoapClient(wsdl)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Username = username
+	c.Password = password
+	c.HttpClient.Timeout = timeout
+
+	return c, nil
+}
+
+func loadClient(wsdl, username, password string, timeout time.Duration) (*gosoap.Client, error) {
+	c, err := newClient(wsdl, username, password, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.LoadDefinitions(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tiaguinho/gosoap"
+)
+
+func testWsdl(t *testing.T, name string) string {
+	dir, _ := os.Getwd()
+
+	return fmt.Sprintf("file://%s/../../testdata/%s", dir, name)
+}
+
+func TestRun_Describe(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	if code := run([]string{"operations", testWsdl(t, "orders.wsdl")}, &out, &errOut); code != 0 {
+		t.Fatalf("exit code %d: %s", code, errOut.String())
+	}
+
+	if out.String() != "CreateOrder\nGetOrder\n" {
+		t.Errorf("unexpected operations: %q", out.String())
+	}
+
+	out.Reset()
+	if code := run([]string{"describe", testWsdl(t, "orders.wsdl"), "CreateOrder"}, &out, &errOut); code != 0 {
+		t.Fatalf("exit code %d: %s", code, errOut.String())
+	}
+
+	for _, want := range []string{"    CreateOrder\n", "      item tns:Item [1..*]\n", "        zip xs:string [optional]\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("describe output must contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	if code := run([]string{"unknown"}, &out, &errOut); code != 2 {
+		t.Errorf("unknown command must exit with 2, got %d", code)
+	}
+}
+
+func TestRun_Lint(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	if code := run([]string{"lint", testWsdl(t, "orders.wsdl")}, &out, &errOut); code != 0 || out.Len() != 0 {
+		t.Fatalf("exit code %d: %s%s", code, out.String(), errOut.String())
+	}
+
+	if code := run([]string{"lint", testWsdl(t, "broken.wsdl")}, &out, &errOut); code != 1 {
+		t.Fatalf("exit code 1 expected, got %d", code)
+	}
+
+	if !strings.HasPrefix(out.String(), "line 8: element \"at\" references undefined type \"tns:Timestamp\"\n") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+	if errOut.String() != "gosoap: 11 warnings\n" {
+		t.Errorf("unexpected error output %q", errOut.String())
+	}
+
+	wsdl, err := ioutil.ReadFile("../../testdata/orders.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write(wsdl)
+	}))
+	defer ts.Close()
+
+	out.Reset()
+	errOut.Reset()
+	if code := run([]string{"lint", "-username", "user", "-password", "secret", "-timeout", "5s", ts.URL}, &out, &errOut); code != 0 || out.Len() != 0 {
+		t.Errorf("exit code %d: %s%s", code, out.String(), errOut.String())
+	}
+
+	if code := run([]string{"lint", ts.URL}, &out, &errOut); code != 1 {
+		t.Errorf("lint without credentials must fail, got exit code %d", code)
+	}
+}
+
+func TestRun_ClientFlags(t *testing.T) {
+	wsdl, err := ioutil.ReadFile("../../testdata/orders.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write(wsdl)
+	}))
+	defer ts.Close()
+
+	for _, args := range [][]string{
+		{"services", ts.URL},
+		{"operations", ts.URL},
+		{"describe", ts.URL, "GetOrder"},
+		{"sample", "-format", "xml", ts.URL, "GetOrder"},
+	} {
+		var out, errOut bytes.Buffer
+		withFlags := append([]string{args[0], "-username", "user", "-password", "secret", "-timeout", "5s"}, args[1:]...)
+		if code := run(withFlags, &out, &errOut); code != 0 || out.Len() == 0 {
+			t.Errorf("%s: exit code %d: %s", args[0], code, errOut.String())
+		}
+
+		if code := run(args, &out, &errOut); code != 1 {
+			t.Errorf("%s without credentials must fail, got exit code %d", args[0], code)
+		}
+	}
+}
+
+func TestBuildParams(t *testing.T) {
+	p, err := buildParams(
+		paramFlags{"address.city=Lisbon", "tag=a", "tag=b"},
+		`{"customerId": 42, "express": true}`,
+		"note: hello\naddress:\n  street: Main\n",
+	)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if p["customerId"] != "42" || p["express"] != "true" || p["note"] != "hello" {
+		t.Errorf("unexpected params: %v", p)
+	}
+
+	address, ok := p["address"].(gosoap.Params)
+	if !ok || address["city"] != "Lisbon" || address["street"] != "Main" {
+		t.Errorf("unexpected address: %v", p["address"])
+	}
+
+	if tags, ok := p["tag"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("unexpected tags: %v", p["tag"])
+	}
+
+	if _, err := buildParams(nil, "{", ""); err == nil {
+		t.Errorf("error expected for invalid JSON")
+	}
+}
+
+func TestRun_Call(t *testing.T) {
+	data, err := ioutil.ReadFile("../../testdata/orders.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write(regexp.MustCompile(`location="[^"]*"`).ReplaceAll(data, []byte(`location="`+ts.URL+`"`)))
+			return
+		}
+
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<GetOrderResponse xmlns="http://example.com/orders"><orderId>7</orderId><status>NEW</status></GetOrderResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	}))
+	defer ts.Close()
+
+	var out, errOut bytes.Buffer
+	if code := run([]string{"call", "-p", "orderId=7", "-format", "json", ts.URL, "GetOrder"}, &out, &errOut); code != 0 {
+		t.Fatalf("exit code %d: %s", code, errOut.String())
+	}
+
+	if !strings.Contains(out.String(), `"orderId": "7"`) {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
+
+func TestRun_Sample(t *testing.T) {
+	var out, errOut bytes.Buffer
+
+	if code := run([]string{"sample", testWsdl(t, "orders.wsdl"), "GetOrder"}, &out, &errOut); code != 0 {
+		t.Fatalf("exit code %d: %s", code, errOut.String())
+	}
+
+	if out.String() != "{\n  \"orderId\": \"?\"\n}\n" {
+		t.Errorf("unexpected sample: %q", out.String())
+	}
+
+	out.Reset()
+	if code := run([]string{"sample", "-format", "xml", testWsdl(t, "orders.wsdl"), "GetOrder"}, &out, &errOut); code != 0 {
+		t.Fatalf("exit code %d: %s", code, errOut.String())
+	}
+
+	if !strings.Contains(out.String(), "<orderId>?</orderId>") {
+		t.Errorf("unexpected sample: %s", out.String())
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/tiaguinho/gosoap"
+)
+
+// printTree writes the schema element and its children, one per line
+func printTree(w io.Writer, e *gosoap.SchemaElement, indent string) {
+	line := indent + e.Name
+	if e.Type != "" {
+		line += " " + e.Type
+	}
+
+	switch {
+	case e.IsOptional() && e.IsRepeated():
+		line += " [0..*]"
+	case e.IsRepeated():
+		line += fmt.Sprintf(" [%d..*]", e.MinOccurs)
+	case e.IsOptional():
+		line += " [optional]"
+	}
+
+	if e.Nillable {
+		line += " nillable"
+	}
+
+	fmt.Fprintln(w, line)
+	for _, c := range e.Children {
+		printTree(w, c, indent+"  ")
+	}
+}
+
+// writeXML indents the body fragment of the response
+func writeXML(w io.Writer, body []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if cd, ok := t.(xml.CharData); ok && len(bytes.TrimSpace(cd)) == 0 {
+			continue
+		}
+
+		if err := e.EncodeToken(t); err != nil {
+			return err
+		}
+	}
+
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// writeJSON converts the body of the response to the operation to indented JSON
+func writeJSON(w io.Writer, c *gosoap.Client, operation string, body []byte) error {
+	b, err := c.Definitions.BodyToJSON(operation, body)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, out.String())
+	return err
+}
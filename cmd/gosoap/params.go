@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/tiaguinho/gosoap"
+	"gopkg.in/yaml.v2"
+)
+
+// paramFlags collects the repeated -p name=value flags
+type paramFlags []string
+
+func (p *paramFlags) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *paramFlags) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("param %q must be in the form name=value", v)
+	}
+
+	*p = append(*p, v)
+	return nil
+}
+
+// buildParams merges the JSON and YAML documents and the -p flags, in that order
+func buildParams(flags paramFlags, jsonDoc, yamlDoc string) (gosoap.Params, error) {
+	p := gosoap.Params{}
+
+	if jsonDoc != "" {
+		data, err := readArg(jsonDoc)
+		if err != nil {
+			return nil, err
+		}
+
+		var v map[string]interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON params: %s", err)
+		}
+
+		for k, val := range v {
+			p[k] = normalize(val)
+		}
+	}
+
+	if yamlDoc != "" {
+		data, err := readArg(yamlDoc)
+		if err != nil {
+			return nil, err
+		}
+
+		var v map[string]interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid YAML params: %s", err)
+		}
+
+		for k, val := range v {
+			p[k] = normalize(val)
+		}
+	}
+
+	for _, f := range flags {
+		i := strings.Index(f, "=")
+		if err := setPath(p, strings.Split(f[:i], "."), f[i+1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// setPath stores v under the dotted name path, repeating a name makes a list
+func setPath(p gosoap.Params, path []string, v string) error {
+	k := path[0]
+	if len(path) == 1 {
+		switch cur := p[k].(type) {
+		case nil:
+			p[k] = v
+		case string:
+			p[k] = []interface{}{cur, v}
+		case []interface{}:
+			p[k] = append(cur, v)
+		default:
+			return fmt.Errorf("param %q already holds nested params", k)
+		}
+
+		return nil
+	}
+
+	child, ok := p[k].(gosoap.Params)
+	if !ok {
+		if p[k] != nil {
+			return fmt.Errorf("param %q already holds a value", k)
+		}
+		child = gosoap.Params{}
+		p[k] = child
+	}
+
+	return setPath(child, path[1:], v)
+}
+
+// normalize converts decoded JSON and YAML values into the forms understood
+// by the request encoder: Params, slices and strings
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		p := gosoap.Params{}
+		for k, val := range v {
+			p[k] = normalize(val)
+		}
+		return p
+	case map[interface{}]interface{}:
+		p := gosoap.Params{}
+		for k, val := range v {
+			p[fmt.Sprint(k)] = normalize(val)
+		}
+		return p
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = normalize(val)
+		}
+		return l
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// readArg returns the content of the file named by an @file argument or the argument itself
+func readArg(a string) ([]byte, error) {
+	if strings.HasPrefix(a, "@") {
+		return ioutil.ReadFile(a[1:])
+	}
+
+	return []byte(a), nil
+}
@@ -0,0 +1,115 @@
+package gosoap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Compression configures the content encoding of the requests and responses
+type Compression struct {
+	// Request encodes the request envelope, "gzip" or "deflate", empty sends it as is
+	Request string
+	// Accept lists the encodings advertised in Accept-Encoding. Compressed responses
+	// are decoded whether they were asked for or not
+	Accept []string
+}
+
+// TransferSizes of the envelopes of a call, Wire sizes are the ones sent or read
+// on the connection, after compression
+type TransferSizes struct {
+	Request     int64
+	RequestWire int64
+	Response    int64
+	// ResponseWire is -1 if the http transport decoded the response itself
+	ResponseWire int64
+}
+
+// WithCompression encodes the request envelopes with request, "gzip", "deflate" or
+// empty, and advertises the accept encodings, among the ones responses can be decoded from
+func WithCompression(request string, accept ...string) Option {
+	return func(c *Client) error {
+		comp := &Compression{Request: request, Accept: accept}
+		if _, err := comp.encode(nil); err != nil {
+			return err
+		}
+
+		for _, a := range accept {
+			switch strings.ToLower(strings.TrimSpace(a)) {
+			case "gzip", "x-gzip", "deflate", "identity":
+			default:
+				return fmt.Errorf("unsupported accept encoding %q", a)
+			}
+		}
+
+		c.Compression = comp
+		return nil
+	}
+}
+
+// encode compresses b with the request encoding
+func (comp *Compression) encode(b []byte) ([]byte, error) {
+	if comp == nil || comp.Request == "" {
+		return b, nil
+	}
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+
+	switch strings.ToLower(comp.Request) {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported request encoding %q", comp.Request)
+	}
+
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decodeBody returns the decoded content of r, encoded with the Content-Encoding encoding
+func decodeBody(encoding string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return r, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		// deflate is meant to be zlib wrapped, some servers send raw deflate data
+		br := bufio.NewReader(r)
+		h, _ := br.Peek(2)
+		if len(h) == 2 && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+
+	return nil, fmt.Errorf("unsupported response encoding %q", encoding)
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
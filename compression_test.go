@@ -0,0 +1,128 @@
+package gosoap
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	body := fmt.Sprintf(getOrderResponse, "1", strings.Repeat("<note>compressible</note>", 100))
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		encoding string
+		wantReq  string
+		wantAcc  string
+	}{
+		{
+			name:     "gzip",
+			opts:     []Option{WithCompression("gzip", "gzip", "deflate")},
+			encoding: "gzip",
+			wantReq:  "gzip",
+			wantAcc:  "gzip, deflate",
+		},
+		{
+			name:     "deflate",
+			opts:     []Option{WithCompression("deflate", "deflate")},
+			encoding: "deflate",
+			wantReq:  "deflate",
+			wantAcc:  "deflate",
+		},
+		{
+			name:     "raw deflate",
+			opts:     []Option{WithCompression("", "deflate")},
+			encoding: "raw",
+			wantAcc:  "deflate",
+		},
+		{
+			name:     "not asked",
+			opts:     []Option{func(c *Client) error { c.roundTripper.DisableCompression = true; return nil }},
+			encoding: "gzip",
+		},
+		{
+			name: "identity",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Content-Encoding"); got != tt.wantReq {
+					t.Errorf("unexpected request encoding %q", got)
+				}
+				if got := r.Header.Get("Accept-Encoding"); tt.wantAcc != "" && got != tt.wantAcc {
+					t.Errorf("unexpected accept encoding %q", got)
+				}
+
+				rd, err := decodeBody(r.Header.Get("Content-Encoding"), r.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if req, _ := ioutil.ReadAll(rd); !bytes.Contains(req, []byte("<orderId>1</orderId>")) {
+					t.Errorf("unexpected request %s", req)
+				}
+
+				var wr io.WriteCloser
+				ce := tt.encoding
+				switch tt.encoding {
+				case "gzip":
+					wr = gzip.NewWriter(w)
+				case "deflate":
+					wr = zlib.NewWriter(w)
+				case "raw":
+					wr, _ = flate.NewWriter(w, flate.DefaultCompression)
+					ce = "deflate"
+				default:
+					w.Write([]byte(body))
+					return
+				}
+
+				w.Header().Set("Content-Encoding", ce)
+				wr.Write([]byte(body))
+				wr.Close()
+			})
+			defer ts.Close()
+
+			c, err := NewClient(ts.URL+"?wsdl", tt.opts...)
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+
+			res, err := c.Call("GetOrder", Params{"orderId": "1"})
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+
+			if !bytes.Contains(res.Body, []byte("<orderId>1</orderId>")) {
+				t.Errorf("unexpected body %s", res.Body)
+			}
+
+			s := res.Sizes
+			if s.Request != int64(len(res.Payload)) || s.Response != int64(len(body)) {
+				t.Errorf("unexpected sizes %+v", s)
+			}
+			if tt.wantReq != "" && s.RequestWire >= s.Request {
+				t.Errorf("request must be compressed %+v", s)
+			}
+			if tt.encoding != "" && (s.ResponseWire <= 0 || s.ResponseWire >= s.Response) {
+				t.Errorf("response must be compressed %+v", s)
+			}
+		})
+	}
+
+	if _, err := NewClient("http://localhost/service?wsdl", WithCompression("br")); err == nil {
+		t.Errorf("error expected")
+	}
+
+	if _, err := NewClient("http://localhost/service?wsdl", WithCompression("gzip", "gzip", "br")); err == nil {
+		t.Errorf("error expected for an accept encoding responses can't be decoded from")
+	}
+}
@@ -0,0 +1,327 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Exchange is the http request and response of a call. Bodies are the envelopes
+// before compression
+type Exchange struct {
+	Method         string
+	URL            string
+	Proto          string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	Status         string
+	ResponseHeader http.Header
+	ResponseBody   []byte
+}
+
+// Redactor hides sensitive data of an Exchange before it's dumped, it gets a copy
+type Redactor func(*Exchange) error
+
+// redactedValue replaces the redacted data
+const redactedValue = "REDACTED"
+
+// RedactHeaders returns a Redactor hiding the values of the request and response headers names
+func RedactHeaders(names ...string) Redactor {
+	return func(x *Exchange) error {
+		for _, n := range names {
+			for _, h := range []http.Header{x.RequestHeader, x.ResponseHeader} {
+				if _, ok := h[http.CanonicalHeaderKey(n)]; ok {
+					h.Set(n, redactedValue)
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// RedactElements returns a Redactor hiding the text of the elements with the local
+// names in the request and response envelopes. Bodies that aren't xml, such as an
+// html error page, are elided. Request.String hides the params with these names too
+func RedactElements(names ...string) Redactor {
+	redactedNames.add(names)
+
+	redact := func(r xml.TokenReader) xml.TokenReader {
+		return &redactReader{r: r, names: operationSet(names)}
+	}
+
+	body := func(b []byte) []byte {
+		r, err := transformTokens(b, "", redact)
+		if err != nil {
+			return []byte(fmt.Sprintf("[%d bytes elided, not xml: %s]", len(b), err))
+		}
+
+		return r
+	}
+
+	return func(x *Exchange) error {
+		x.RequestBody, x.ResponseBody = body(x.RequestBody), body(x.ResponseBody)
+		return nil
+	}
+}
+
+// redactedNames are the local names given to RedactElements, hidden by Request.String
+var redactedNames = &nameSet{names: map[string]bool{}}
+
+type nameSet struct {
+	mu    sync.RWMutex
+	names map[string]bool
+}
+
+func (s *nameSet) add(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, n := range names {
+		s.names[n] = true
+	}
+}
+
+func (s *nameSet) has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.names[localName(name)]
+}
+
+// redactRaw hides the text of the elements of redactedNames in the fragment raw
+func (s *nameSet) redactRaw(raw RawXML) string {
+	s.mu.RLock()
+	names := make(map[string]bool, len(s.names))
+	for n := range s.names {
+		names[n] = true
+	}
+	s.mu.RUnlock()
+
+	if len(names) == 0 {
+		return string(raw)
+	}
+
+	rr := &redactReader{names: names}
+	b, err := transformTokens([]byte(raw), "", func(r xml.TokenReader) xml.TokenReader {
+		rr.r = r
+		return rr
+	})
+	if err != nil {
+		return fmt.Sprintf("[%d bytes elided, not xml: %s]", len(raw), err)
+	}
+
+	// the fragment is kept as written when there's nothing to hide
+	if !rr.redacted {
+		return string(raw)
+	}
+
+	return string(b)
+}
+
+type redactReader struct {
+	r     xml.TokenReader
+	names map[string]bool
+	open  []bool
+	// redacted reports whether a text was hidden
+	redacted bool
+}
+
+func (r *redactReader) Token() (xml.Token, error) {
+	t, err := r.r.Token()
+	if err != nil {
+		return t, err
+	}
+
+	switch tt := t.(type) {
+	case xml.StartElement:
+		r.open = append(r.open, r.names[tt.Name.Local] || (len(r.open) > 0 && r.open[len(r.open)-1]))
+	case xml.EndElement:
+		if len(r.open) > 0 {
+			r.open = r.open[:len(r.open)-1]
+		}
+	case xml.CharData:
+		if len(r.open) > 0 && r.open[len(r.open)-1] && len(bytes.TrimSpace(tt)) > 0 {
+			r.redacted = true
+			return xml.CharData(redactedValue), nil
+		}
+	}
+
+	return t, nil
+}
+
+// DumpExchange returns the http request and response of x, in the format of
+// httputil.DumpRequestOut and httputil.DumpResponse. The Authorization, Cookie and
+// Set-Cookie headers are always redacted, the redactors hide anything else
+func DumpExchange(x *Exchange, redact ...Redactor) ([]byte, error) {
+	c := *x
+	c.RequestHeader, c.ResponseHeader = x.RequestHeader.Clone(), x.ResponseHeader.Clone()
+	c.RequestBody = append([]byte(nil), x.RequestBody...)
+	c.ResponseBody = append([]byte(nil), x.ResponseBody...)
+
+	for _, r := range append([]Redactor{RedactHeaders("Authorization", "Cookie", "Set-Cookie")}, redact...) {
+		if err := r(&c); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+
+	path, host := c.URL, ""
+	if u, err := url.Parse(c.URL); err == nil {
+		path, host = u.RequestURI(), u.Host
+	}
+
+	fmt.Fprintf(&b, "%s %s %s\n", c.Method, path, c.Proto)
+	fmt.Fprintf(&b, "Host: %s\n", host)
+	writeHeader(&b, c.RequestHeader)
+	b.WriteString("\n")
+	b.Write(c.RequestBody)
+	b.WriteString("\n\n")
+
+	fmt.Fprintf(&b, "%s %s\n", c.Proto, c.Status)
+	writeHeader(&b, c.ResponseHeader)
+	b.WriteString("\n")
+	b.Write(c.ResponseBody)
+	b.WriteString("\n")
+
+	return b.Bytes(), nil
+}
+
+func writeHeader(b *bytes.Buffer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(b, "%s: %s\n", k, v)
+		}
+	}
+}
+
+// RequestEnvelope returns the envelope of the request, same as Payload
+func (r *Response) RequestEnvelope() []byte {
+	return r.Payload
+}
+
+// String returns the body of the response indented
+func (r *Response) String() string {
+	b, err := transformTokens(r.Body, "  ", dropWhitespace)
+	if err != nil {
+		return string(r.Body)
+	}
+
+	return string(b)
+}
+
+// Format writes the body with the verbs %s and %v, and the dump of the whole
+// http exchange with %+v
+func (r *Response) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('+') && r.Exchange != nil {
+		if b, err := DumpExchange(r.Exchange); err == nil {
+			f.Write(b)
+			return
+		}
+	}
+
+	switch verb {
+	case 'q':
+		fmt.Fprintf(f, "%q", r.String())
+	default:
+		f.Write([]byte(r.String()))
+	}
+}
+
+// dropWhitespace removes the whitespace between elements
+func dropWhitespace(r xml.TokenReader) xml.TokenReader {
+	return tokenReaderFunc(func() (xml.Token, error) {
+		for {
+			t, err := r.Token()
+			if c, ok := t.(xml.CharData); ok && err == nil && len(bytes.TrimSpace(c)) == 0 {
+				continue
+			}
+			return t, err
+		}
+	})
+}
+
+type tokenReaderFunc func() (xml.Token, error)
+
+func (f tokenReaderFunc) Token() (xml.Token, error) {
+	return f()
+}
+
+// String returns the operation and its params, e.g. GetOrder(orderId=1). The values
+// of the elements given to RedactElements are redacted
+func (r *Request) String() string {
+	if r.Body != "" {
+		return fmt.Sprintf("%s(%s)", r.Method, redactedNames.redactRaw(r.Body))
+	}
+
+	params := formatParams(r.Params)
+	if a := formatParams(r.ArrayParams); a != "" && params != "" {
+		params += ", " + a
+	} else if a != "" {
+		params = a
+	}
+
+	return fmt.Sprintf("%s(%s)", r.Method, params)
+}
+
+func formatParams(p interface{}) string {
+	var s []string
+
+	switch p := p.(type) {
+	case nil:
+		return ""
+	case Params:
+		return formatParams(map[string]interface{}(p))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(p))
+		for k := range p {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			s = append(s, k+"="+formatParam(k, p[k]))
+		}
+	case ArrayParams:
+		for _, kv := range p {
+			k := fmt.Sprintf("%v", kv[0])
+			s = append(s, k+"="+formatParam(k, kv[1]))
+		}
+	default:
+		return fmt.Sprintf("%v", p)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// formatParam returns the value v of the param name, redacted if name was given to RedactElements
+func formatParam(name string, v interface{}) string {
+	if redactedNames.has(name) {
+		return redactedValue
+	}
+
+	return formatValue(v)
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case Params, map[string]interface{}, ArrayParams:
+		return "{" + formatParams(v) + "}"
+	case RawXML:
+		return redactedNames.redactRaw(v)
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,102 @@
+package gosoap
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDumpExchange(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("Set-Cookie", "SESSION=secret")
+		fmt.Fprintf(w, getOrderResponse, "1", "")
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	c.Username, c.Password = "user", "secret"
+
+	res, err := c.Call("GetOrder", Params{"orderId": "1"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	b, err := DumpExchange(res.Exchange, RedactElements("orderId"))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	dump := string(b)
+	for _, want := range []string{
+		"POST /soap HTTP/1.1\nHost: " + strings.TrimPrefix(ts.URL, "http://") + "\n",
+		"Authorization: REDACTED\n",
+		"Soapaction: http://example.com/orders/GetOrder\n",
+		"<orderId>REDACTED</orderId>",
+		"\n\nHTTP/1.1 200 OK\n",
+		"Set-Cookie: REDACTED\n",
+		"<GetOrderResponse xmlns=\"http://example.com/orders\"><orderId>REDACTED</orderId><status>NEW</status>",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump must contain %q, got:\n%s", want, dump)
+		}
+	}
+
+	if strings.Contains(dump, ">1<") || strings.Contains(res.Exchange.ResponseHeader.Get("Set-Cookie"), "REDACTED") {
+		t.Errorf("redaction must apply to a copy of the exchange")
+	}
+
+	if got := fmt.Sprintf("%+v", res); !strings.Contains(got, "POST /soap") || !strings.Contains(got, "<orderId>1</orderId>") {
+		t.Errorf("unexpected %%+v output:\n%s", got)
+	}
+
+	want := "<GetOrderResponse xmlns=\"http://example.com/orders\">\n  <orderId>1</orderId>\n  <status>NEW</status>\n</GetOrderResponse>"
+	if got := fmt.Sprint(res); got != want {
+		t.Errorf("unexpected String() output:\n%s\nwant:\n%s", got, want)
+	}
+
+	// an html error page can't be redacted, it's elided
+	x := &Exchange{Method: "POST", URL: ts.URL, Proto: "HTTP/1.1", Status: "502 Bad Gateway",
+		RequestHeader: http.Header{"Cookie": {"SESSION=secret"}}, RequestBody: res.Exchange.RequestBody,
+		ResponseBody: []byte("<html><body><p>token=secret</body></html>")}
+	b, err = DumpExchange(x, RedactElements("orderId"))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if dump := string(b); strings.Contains(dump, "secret") || !strings.Contains(dump, "Cookie: REDACTED\n") ||
+		!strings.Contains(dump, "<orderId>REDACTED</orderId>") || !strings.Contains(dump, "bytes elided, not xml") {
+		t.Errorf("unexpected dump:\n%s", dump)
+	}
+
+	if string(res.RequestEnvelope()) != string(res.Exchange.RequestBody) || string(res.Envelope) != string(res.Exchange.ResponseBody) {
+		t.Errorf("envelopes must match the exchange")
+	}
+}
+
+func TestRequest_String(t *testing.T) {
+	tests := []struct {
+		req  *Request
+		want string
+	}{
+		{NewRequest("GetOrder", Params{"id": "1", "express": true}), "GetOrder(express=true, id=1)"},
+		{NewArrayRequest("CreateOrder", ArrayParams{{"customerId", "c1"}, {"item", ArrayParams{{"sku", "ABC-0001"}, {"quantity", 2}}}}), "CreateOrder(customerId=c1, item={sku=ABC-0001, quantity=2})"},
+		{NewRawRequest("GetOrder", "<GetOrder/>"), "GetOrder(<GetOrder/>)"},
+		{NewRequest("Ping", nil), "Ping()"},
+		{NewRequest("Login", Params{"user": "robert", "secret": "s3", "card": Params{"pan": "4111"}}), "Login(card={pan=REDACTED}, secret=REDACTED, user=robert)"},
+		{NewArrayRequest("Login", ArrayParams{{"b:secret", "s3"}, {"raw", RawXML("<pan>4111</pan>")}}), "Login(b:secret=REDACTED, raw=<pan>REDACTED</pan>)"},
+		{NewRawRequest("Login", "<Login><secret>s3</secret></Login>"), "Login(<Login><secret>REDACTED</secret></Login>)"},
+	}
+
+	// the params given to RedactElements are hidden
+	RedactElements("secret", "pan")
+
+	for _, tt := range tests {
+		if got := tt.req.String(); got != tt.want {
+			t.Errorf("String() = %s, want %s", got, tt.want)
+		}
+	}
+}
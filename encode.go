@@ -4,37 +4,50 @@ import (
 	"encoding/xml"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 // MarshalXML envelope the body and encode to xml
 func (c process) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
-	tokens := &tokenData{}
-
 	//start envelope
 	if c.Client.Definitions == nil {
 		return fmt.Errorf("definitions is nil")
 	}
 
+	tokens := c.Client.newTokenData(c.Client.Definitions.Types[0].XsdSchema[0])
 	tokens.startEnvelope()
 	if len(c.Client.HeaderParams) > 0 {
 		tokens.startHeader(c.Client.HeaderName, c.Client.Definitions.Types[0].XsdSchema[0].TargetNamespace)
 
-		tokens.recursiveEncode(c.Client.HeaderParams)
+		tokens.recursiveEncode(c.Client.HeaderParams, nil)
 
 		tokens.endHeader(c.Client.HeaderName)
 	}
 
-	err := tokens.startBody(c.Request.Method, c.Client.Definitions.Types[0].XsdSchema[0].TargetNamespace)
-	if err != nil {
-		return err
-	}
+	if c.Request.Body != "" {
+		tokens.encodeRawBody(c.Request.Body)
+	} else {
+		err := tokens.startBody(c.Request.Method, c.Client.Definitions.Types[0].XsdSchema[0].TargetNamespace)
+		if err != nil {
+			return err
+		}
 
-	tokens.recursiveEncode(c.Request.Params)
+		// the elements of Params maps follow the schema sequence when the operation is known
+		s, _ := c.Client.Definitions.InputSchema(c.Request.Method)
+		tokens.recursiveEncode(c.Request.Params, s)
+		tokens.recursiveEncode(c.Request.ArrayParams, s)
+
+		tokens.endBody(c.Request.Method)
+	}
 
 	//end envelope
-	tokens.endBody(c.Request.Method)
 	tokens.endEnvelope()
 
+	if tokens.err != nil {
+		return tokens.err
+	}
+
 	for _, t := range tokens.data {
 		err := e.EncodeToken(t)
 		if err != nil {
@@ -47,28 +60,78 @@ func (c process) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
 
 type tokenData struct {
 	data []xml.Token
+	ns   EnvelopeNamespaces
+	// namespace of the operation, declared on the envelope when ns.OperationPrefix is set
+	namespace string
+	// qualified is set when the schema elementFormDefault is qualified
+	qualified bool
+	// paramPrefix qualifies the params elements of the body
+	paramPrefix string
+	// soapNamespace of the envelope, SOAP 1.1 or 1.2 following the port binding
+	soapNamespace string
+	// err is the first error found while encoding RawXML
+	err error
 }
 
-func (tokens *tokenData) recursiveEncode(hm interface{}) {
+// newTokenData returns the tokenData of a request to the operations of schema s
+func (c *Client) newTokenData(s *xsdSchema) *tokenData {
+	tokens := &tokenData{ns: DefaultEnvelopeNamespaces, soapNamespace: soapNamespace(c.Definitions.soapVersion())}
+	if c.Namespaces != nil {
+		tokens.ns = *c.Namespaces
+	}
+	tokens.ns = tokens.ns.withDefaults()
+
+	if tokens.ns.OperationPrefix != "" {
+		tokens.namespace = s.TargetNamespace
+		tokens.qualified = s.ElementFormDefault == "qualified"
+	}
+
+	return tokens
+}
+
+// paramName returns the element name of the param key k
+func (tokens *tokenData) paramName(k string) xml.Name {
+	if strings.Contains(k, ":") {
+		return xml.Name{Space: "", Local: k}
+	}
+
+	return xml.Name{Space: "", Local: tokens.paramPrefix + k}
+}
+
+// recursiveEncode appends the elements of hm, s is the schema of the element holding them or nil
+func (tokens *tokenData) recursiveEncode(hm interface{}, s *SchemaElement) {
+	if r, ok := hm.(RawXML); ok {
+		tokens.encodeRaw(r)
+		return
+	}
+
 	v := reflect.ValueOf(hm)
 
 	switch v.Kind() {
 	case reflect.Map:
-		for _, key := range v.MapKeys() {
+		for _, key := range sequenceKeys(v.MapKeys(), s) {
 			t := xml.StartElement{
-				Name: xml.Name{
-					Space: "",
-					Local: key.String(),
-				},
+				Name: tokens.paramName(key.String()),
 			}
 
 			tokens.data = append(tokens.data, t)
-			tokens.recursiveEncode(v.MapIndex(key).Interface())
+			tokens.recursiveEncode(v.MapIndex(key).Interface(), schemaChild(s, key.String()))
 			tokens.data = append(tokens.data, xml.EndElement{Name: t.Name})
 		}
 	case reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
-			tokens.recursiveEncode(v.Index(i).Interface())
+			tokens.recursiveEncode(v.Index(i).Interface(), s)
+		}
+	case reflect.Array:
+		if v.Len() == 2 {
+			k := fmt.Sprint(v.Index(0).Interface())
+			t := xml.StartElement{
+				Name: tokens.paramName(k),
+			}
+
+			tokens.data = append(tokens.data, t)
+			tokens.recursiveEncode(v.Index(1).Interface(), schemaChild(s, k))
+			tokens.data = append(tokens.data, xml.EndElement{Name: t.Name})
 		}
 	case reflect.String:
 		content := xml.CharData(v.String())
@@ -76,28 +139,102 @@ func (tokens *tokenData) recursiveEncode(hm interface{}) {
 	}
 }
 
+// sequenceKeys sorts the keys of a map in the sequence order of s,
+// keys not declared in s follow in alphabetical order
+func sequenceKeys(keys []reflect.Value, s *SchemaElement) []reflect.Value {
+	index := func(k reflect.Value) int {
+		if s == nil {
+			return -1
+		}
+		if i := childIndex(s, localName(k.String())); i >= 0 {
+			return i
+		}
+
+		return len(s.Children)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := index(keys[i]), index(keys[j])
+		if a != b {
+			return a < b
+		}
+
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
+
+// schemaChild returns the child of s named by the param key k, nil if unknown
+func schemaChild(s *SchemaElement, k string) *SchemaElement {
+	if s == nil {
+		return nil
+	}
+	if i := childIndex(s, localName(k)); i >= 0 {
+		return s.Children[i]
+	}
+
+	return nil
+}
+
+func (tokens *tokenData) encodeRaw(r RawXML) {
+	t, err := r.tokens()
+	if err != nil {
+		if tokens.err == nil {
+			tokens.err = err
+		}
+		return
+	}
+
+	tokens.data = append(tokens.data, t...)
+}
+
+// encodeRawBody writes the body of a raw request, the operation element included
+func (tokens *tokenData) encodeRawBody(r RawXML) {
+	tokens.data = append(tokens.data, xml.StartElement{
+		Name: tokens.ns.soapName("Body"),
+		Attr: namespaceAttrs(tokens.ns.Body),
+	})
+	tokens.encodeRaw(r)
+	tokens.data = append(tokens.data, xml.EndElement{Name: tokens.ns.soapName("Body")})
+}
+
 func (tokens *tokenData) startEnvelope() {
+	decls := map[string]string{
+		tokens.ns.XsiPrefix:  "http://www.w3.org/2001/XMLSchema-instance",
+		tokens.ns.XsdPrefix:  "http://www.w3.org/2001/XMLSchema",
+		tokens.ns.SoapPrefix: tokens.soapNamespace,
+	}
+
 	e := xml.StartElement{
-		Name: xml.Name{
-			Space: "",
-			Local: "soap:Envelope",
-		},
+		Name: tokens.ns.soapName("Envelope"),
 		Attr: []xml.Attr{
-			{Name: xml.Name{Space: "", Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
-			{Name: xml.Name{Space: "", Local: "xmlns:xsd"}, Value: "http://www.w3.org/2001/XMLSchema"},
-			{Name: xml.Name{Space: "", Local: "xmlns:soap"}, Value: "http://schemas.xmlsoap.org/soap/envelope/"},
+			{Name: xml.Name{Space: "", Local: "xmlns:" + tokens.ns.XsiPrefix}, Value: decls[tokens.ns.XsiPrefix]},
+			{Name: xml.Name{Space: "", Local: "xmlns:" + tokens.ns.XsdPrefix}, Value: decls[tokens.ns.XsdPrefix]},
+			{Name: xml.Name{Space: "", Local: "xmlns:" + tokens.ns.SoapPrefix}, Value: decls[tokens.ns.SoapPrefix]},
 		},
 	}
 
+	extra := map[string]string{}
+	if tokens.ns.OperationPrefix != "" {
+		extra[tokens.ns.OperationPrefix] = tokens.namespace
+	}
+	for p, n := range tokens.ns.Envelope {
+		extra[p] = n
+	}
+	for p := range extra {
+		if _, ok := decls[p]; ok {
+			delete(extra, p)
+		}
+	}
+
+	e.Attr = append(e.Attr, namespaceAttrs(extra)...)
 	tokens.data = append(tokens.data, e)
 }
 
 func (tokens *tokenData) endEnvelope() {
 	e := xml.EndElement{
-		Name: xml.Name{
-			Space: "",
-			Local: "soap:Envelope",
-		},
+		Name: tokens.ns.soapName("Envelope"),
 	}
 
 	tokens.data = append(tokens.data, e)
@@ -105,10 +242,8 @@ func (tokens *tokenData) endEnvelope() {
 
 func (tokens *tokenData) startHeader(m, n string) {
 	h := xml.StartElement{
-		Name: xml.Name{
-			Space: "",
-			Local: "soap:Header",
-		},
+		Name: tokens.ns.soapName("Header"),
+		Attr: namespaceAttrs(tokens.ns.Header),
 	}
 
 	if m == "" || n == "" {
@@ -133,10 +268,7 @@ func (tokens *tokenData) startHeader(m, n string) {
 
 func (tokens *tokenData) endHeader(m string) {
 	h := xml.EndElement{
-		Name: xml.Name{
-			Space: "",
-			Local: "soap:Header",
-		},
+		Name: tokens.ns.soapName("Header"),
 	}
 
 	if m == "" {
@@ -157,10 +289,8 @@ func (tokens *tokenData) endHeader(m string) {
 // startToken initiate body of the envelope
 func (tokens *tokenData) startBody(m, n string) error {
 	b := xml.StartElement{
-		Name: xml.Name{
-			Space: "",
-			Local: "soap:Body",
-		},
+		Name: tokens.ns.soapName("Body"),
+		Attr: namespaceAttrs(tokens.ns.Body),
 	}
 
 	if m == "" || n == "" {
@@ -177,6 +307,14 @@ func (tokens *tokenData) startBody(m, n string) error {
 		},
 	}
 
+	// the operation namespace is declared on the envelope
+	if tokens.ns.OperationPrefix != "" {
+		r = xml.StartElement{Name: tokens.operationName(m)}
+		if tokens.qualified {
+			tokens.paramPrefix = tokens.ns.OperationPrefix + ":"
+		}
+	}
+
 	tokens.data = append(tokens.data, b, r)
 
 	return nil
@@ -185,18 +323,21 @@ func (tokens *tokenData) startBody(m, n string) error {
 // endToken close body of the envelope
 func (tokens *tokenData) endBody(m string) {
 	b := xml.EndElement{
-		Name: xml.Name{
-			Space: "",
-			Local: "soap:Body",
-		},
+		Name: tokens.ns.soapName("Body"),
 	}
 
 	r := xml.EndElement{
-		Name: xml.Name{
-			Space: "",
-			Local: m,
-		},
+		Name: tokens.operationName(m),
 	}
 
 	tokens.data = append(tokens.data, r, b)
 }
+
+// operationName returns the name of the operation element m
+func (tokens *tokenData) operationName(m string) xml.Name {
+	if tokens.ns.OperationPrefix == "" {
+		return xml.Name{Space: "", Local: m}
+	}
+
+	return xml.Name{Space: "", Local: tokens.ns.OperationPrefix + ":" + m}
+}
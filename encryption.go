@@ -0,0 +1,436 @@
+package gosoap
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Algorithms of the body encryption
+const (
+	EncryptionAES128CBC = "http://www.w3.org/2001/04/xmlenc#aes128-cbc"
+	EncryptionAES256CBC = "http://www.w3.org/2001/04/xmlenc#aes256-cbc"
+	EncryptionAES128GCM = "http://www.w3.org/2009/xmlenc11#aes128-gcm"
+	EncryptionAES256GCM = "http://www.w3.org/2009/xmlenc11#aes256-gcm"
+)
+
+const (
+	rsaOAEPMGF1P    = "http://www.w3.org/2001/04/xmlenc#rsa-oaep-mgf1p"
+	xencContent     = "http://www.w3.org/2001/04/xmlenc#Content"
+	thumbprintSHA1  = "http://docs.oasis-open.org/wss/oasis-wss-soap-message-security-1.1#ThumbprintSHA1"
+	sha1DigestAlgo  = "http://www.w3.org/2000/09/xmldsig#sha1"
+	issuerSerialRef = "IssuerSerial"
+)
+
+// Encryption encrypts the content of the request bodies for the Recipient and decrypts
+// the encrypted data of the response bodies with Key, as XML Encryption in a WS-Security
+// 1.1 header: the AES key of each message is wrapped with RSA-OAEP in a xenc:EncryptedKey
+// referencing the xenc:EncryptedData replacing the body content
+type Encryption struct {
+	// Recipient certificate, its RSA public key wraps the keys of the requests
+	Recipient *x509.Certificate
+	// Key is the RSA private key decrypting the responses, they're not decrypted if nil
+	Key *rsa.PrivateKey
+	// Algorithm of the body encryption, EncryptionAES256CBC if empty
+	Algorithm string
+	// KeyIdentifier of the Recipient in the EncryptedKey, the SHA-1 thumbprint if empty
+	// or IssuerSerial for the issuer name and serial number
+	KeyIdentifier string
+}
+
+// WithEncryption encrypts the request bodies and decrypts the responses as configured by e
+func WithEncryption(e Encryption) Option {
+	return func(c *Client) error {
+		if e.Recipient == nil {
+			return fmt.Errorf("encryption recipient certificate is required")
+		}
+		if _, ok := e.Recipient.PublicKey.(*rsa.PublicKey); !ok {
+			return fmt.Errorf("encryption recipient certificate must have an RSA key")
+		}
+		if _, _, err := bodyCipher(e.algorithm()); err != nil {
+			return err
+		}
+
+		c.Encryption = &e
+		return nil
+	}
+}
+
+func (e *Encryption) algorithm() string {
+	if e.Algorithm == "" {
+		return EncryptionAES256CBC
+	}
+
+	return e.Algorithm
+}
+
+// EncryptEnvelope returns the envelope b with its body content encrypted for the Recipient
+func (e *Encryption) EncryptEnvelope(b []byte) ([]byte, error) {
+	pub, ok := e.Recipient.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("encryption recipient certificate must have an RSA key")
+	}
+
+	l, err := parseEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	keySize, _, err := bodyCipher(e.algorithm())
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	data, err := encryptData(e.algorithm(), key, l.bodyContent())
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	edID, err := newSecurityID("ED")
+	if err != nil {
+		return nil, err
+	}
+	ekID, err := newSecurityID("EK")
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey := fmt.Sprintf(`<xenc:EncryptedKey xmlns:xenc="%s" Id="%s">`+
+		`<xenc:EncryptionMethod Algorithm="%s"><ds:DigestMethod xmlns:ds="%s" Algorithm="%s"/></xenc:EncryptionMethod>`+
+		`<ds:KeyInfo xmlns:ds="%s"><wsse:SecurityTokenReference xmlns:wsse="%s">%s</wsse:SecurityTokenReference></ds:KeyInfo>`+
+		`<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData>`+
+		`<xenc:ReferenceList><xenc:DataReference URI="#%s"/></xenc:ReferenceList>`+
+		`</xenc:EncryptedKey>`,
+		xencNamespace, ekID, rsaOAEPMGF1P, dsNamespace, sha1DigestAlgo, dsNamespace, wsseNamespace,
+		e.keyIdentifier(), base64.StdEncoding.EncodeToString(wrapped), edID)
+
+	encryptedData := fmt.Sprintf(`<xenc:EncryptedData xmlns:xenc="%s" Id="%s" Type="%s">`+
+		`<xenc:EncryptionMethod Algorithm="%s"/>`+
+		`<xenc:CipherData><xenc:CipherValue>%s</xenc:CipherValue></xenc:CipherData>`+
+		`</xenc:EncryptedData>`,
+		xencNamespace, edID, xencContent, e.algorithm(), base64.StdEncoding.EncodeToString(data))
+
+	// the body is replaced first, the header comes before it so its offsets are kept
+	b = l.withBodyContent([]byte(encryptedData))
+	if l, err = parseEnvelope(b); err != nil {
+		return nil, err
+	}
+
+	return l.withSecurity([]byte(encryptedKey)), nil
+}
+
+// keyIdentifier returns the reference to the Recipient certificate
+func (e *Encryption) keyIdentifier() string {
+	if e.KeyIdentifier == issuerSerialRef {
+		return fmt.Sprintf(`<ds:X509Data xmlns:ds="%s"><ds:X509IssuerSerial><ds:X509IssuerName>%s</ds:X509IssuerName>`+
+			`<ds:X509SerialNumber>%s</ds:X509SerialNumber></ds:X509IssuerSerial></ds:X509Data>`,
+			dsNamespace, escapeXML(e.Recipient.Issuer.String()), e.Recipient.SerialNumber.String())
+	}
+
+	sum := sha1.Sum(e.Recipient.Raw)
+	return fmt.Sprintf(`<wsse:KeyIdentifier EncodingType="%s" ValueType="%s">%s</wsse:KeyIdentifier>`,
+		wsseBase64Binary, thumbprintSHA1, base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+type xencEncryptedKey struct {
+	ID               string `xml:"Id,attr"`
+	EncryptionMethod struct {
+		Algorithm    string `xml:"Algorithm,attr"`
+		DigestMethod struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
+	} `xml:"http://www.w3.org/2001/04/xmlenc# EncryptionMethod"`
+	CipherValue    string `xml:"http://www.w3.org/2001/04/xmlenc# CipherData>CipherValue"`
+	DataReferences []struct {
+		URI string `xml:"URI,attr"`
+	} `xml:"http://www.w3.org/2001/04/xmlenc# ReferenceList>DataReference"`
+}
+
+type xencEncryptedData struct {
+	ID               string `xml:"Id,attr"`
+	Type             string `xml:"Type,attr"`
+	EncryptionMethod struct {
+		Algorithm string `xml:"Algorithm,attr"`
+	} `xml:"http://www.w3.org/2001/04/xmlenc# EncryptionMethod"`
+	KeyInfo struct {
+		EncryptedKey *xencEncryptedKey `xml:"http://www.w3.org/2001/04/xmlenc# EncryptedKey"`
+		Reference    struct {
+			URI string `xml:"URI,attr"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd SecurityTokenReference>Reference"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+	CipherValue string `xml:"http://www.w3.org/2001/04/xmlenc# CipherData>CipherValue"`
+}
+
+// DecryptEnvelope returns the envelope b with the encrypted data of its body decrypted
+// with Key. The keys are found in the EncryptedKey elements of the security header
+// referencing the data, or in the KeyInfo of the data. b is returned as is if its
+// body holds no encrypted data
+func (e *Encryption) DecryptEnvelope(b []byte) ([]byte, error) {
+	l, err := parseEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*xencEncryptedKey
+	if s := l.security; s.start >= 0 {
+		var sec struct {
+			Keys []*xencEncryptedKey `xml:"http://www.w3.org/2001/04/xmlenc# EncryptedKey"`
+		}
+		if err := decodeElementAt(b, s.start, &sec); err != nil {
+			return nil, err
+		}
+		keys = sec.Keys
+	}
+
+	type located struct {
+		data       xencEncryptedData
+		start, end int
+	}
+
+	// the encrypted data elements of the body, outermost ones only
+	var found []located
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		before := int(d.InputOffset())
+		if before >= l.body.contentEnd {
+			break
+		}
+
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := t.(xml.StartElement)
+		if !ok || before < l.body.contentStart {
+			continue
+		}
+
+		if s.Name.Space == xencNamespace && s.Name.Local == "EncryptedData" {
+			var ed xencEncryptedData
+			if err := d.DecodeElement(&ed, &s); err != nil {
+				return nil, err
+			}
+			found = append(found, located{data: ed, start: before, end: int(d.InputOffset())})
+		}
+	}
+
+	if len(found) == 0 {
+		return b, nil
+	}
+
+	if e.Key == nil {
+		return nil, fmt.Errorf("encrypted response can't be decrypted without a private key")
+	}
+
+	// spliced from the last one so the offsets of the others are kept
+	sort.Slice(found, func(i, j int) bool { return found[i].start > found[j].start })
+	for _, f := range found {
+		ek := f.data.KeyInfo.EncryptedKey
+		if ek == nil {
+			ek = findEncryptedKey(keys, f.data.ID, strings.TrimPrefix(f.data.KeyInfo.Reference.URI, "#"))
+		}
+		if ek == nil {
+			return nil, fmt.Errorf("no EncryptedKey found for EncryptedData %q", f.data.ID)
+		}
+
+		key, err := e.unwrapKey(ek)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(f.data.CipherValue))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CipherValue: %s", err)
+		}
+
+		plain, err := decryptData(f.data.EncryptionMethod.Algorithm, key, data)
+		if err != nil {
+			return nil, err
+		}
+
+		b = splice(b, f.start, f.end, plain)
+	}
+
+	return b, nil
+}
+
+// findEncryptedKey returns the key referencing the data id, or the key with the id ref
+func findEncryptedKey(keys []*xencEncryptedKey, id, ref string) *xencEncryptedKey {
+	for _, k := range keys {
+		if ref != "" && k.ID == ref {
+			return k
+		}
+
+		for _, r := range k.DataReferences {
+			if id != "" && r.URI == "#"+id {
+				return k
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *Encryption) unwrapKey(ek *xencEncryptedKey) ([]byte, error) {
+	if a := ek.EncryptionMethod.Algorithm; a != rsaOAEPMGF1P {
+		return nil, fmt.Errorf("unsupported key transport algorithm %q", a)
+	}
+	if a := ek.EncryptionMethod.DigestMethod.Algorithm; a != "" && a != sha1DigestAlgo {
+		return nil, fmt.Errorf("unsupported key transport digest %q", a)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(strings.TrimSpace(ek.CipherValue))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EncryptedKey CipherValue: %s", err)
+	}
+
+	key, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, e.Key, wrapped, nil)
+	if err != nil {
+		return nil, fmt.Errorf("EncryptedKey can't be decrypted: %s", err)
+	}
+
+	return key, nil
+}
+
+// decodeElementAt decodes the element starting at the offset start of the document b,
+// with the namespaces declared by its ancestors in scope
+func decodeElementAt(b []byte, start int, v interface{}) error {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		before := int(d.InputOffset())
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		if s, ok := t.(xml.StartElement); ok && before == start {
+			return d.DecodeElement(v, &s)
+		}
+	}
+}
+
+// bodyCipher returns the key size and whether the algorithm is GCM
+func bodyCipher(algorithm string) (int, bool, error) {
+	switch algorithm {
+	case EncryptionAES128CBC:
+		return 16, false, nil
+	case EncryptionAES256CBC:
+		return 32, false, nil
+	case EncryptionAES128GCM:
+		return 16, true, nil
+	case EncryptionAES256GCM:
+		return 32, true, nil
+	}
+
+	return 0, false, fmt.Errorf("unsupported encryption algorithm %q", algorithm)
+}
+
+// encryptData returns the IV followed by the cipher text of plain, and the tag with GCM
+func encryptData(algorithm string, key, plain []byte) ([]byte, error) {
+	_, gcm, err := bodyCipher(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if gcm {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		iv := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(iv); err != nil {
+			return nil, err
+		}
+
+		return aead.Seal(iv, iv, plain, nil), nil
+	}
+
+	// XML Encryption padding, the last byte is the padding length
+	n := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := make([]byte, len(plain)+n)
+	copy(padded, plain)
+	if _, err := rand.Read(padded[len(plain) : len(padded)-1]); err != nil {
+		return nil, err
+	}
+	padded[len(padded)-1] = byte(n)
+
+	out := make([]byte, aes.BlockSize+len(padded))
+	if _, err := rand.Read(out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], padded)
+
+	return out, nil
+}
+
+// decryptData reverses encryptData
+func decryptData(algorithm string, key, data []byte) ([]byte, error) {
+	size, gcm, err := bodyCipher(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("key of %d bytes doesn't match %s", len(key), algorithm)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if gcm {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < aead.NonceSize() {
+			return nil, fmt.Errorf("encrypted data too short")
+		}
+
+		plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted data can't be decrypted: %s", err)
+		}
+		return plain, nil
+	}
+
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("encrypted data has an invalid length")
+	}
+
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize {
+		return nil, fmt.Errorf("encrypted data can't be decrypted: invalid padding")
+	}
+
+	return plain[:len(plain)-n], nil
+}
@@ -0,0 +1,211 @@
+package gosoap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a self-signed certificate and its key
+func newTestCertificate(t *testing.T, cn string) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestEncryption(t *testing.T) {
+	clientCert, clientKey := newTestCertificate(t, "client")
+	serverCert, serverKey := newTestCertificate(t, "server")
+
+	for _, algorithm := range []string{EncryptionAES128CBC, EncryptionAES256CBC, EncryptionAES128GCM, EncryptionAES256GCM} {
+		t.Run(algorithm, func(t *testing.T) {
+			server := &Encryption{Recipient: clientCert, Key: serverKey, Algorithm: algorithm, KeyIdentifier: "IssuerSerial"}
+
+			ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				if bytes.Contains(body, []byte("<orderId>")) || !bytes.Contains(body, []byte("xenc:EncryptedData")) {
+					t.Errorf("body must be encrypted:\n%s", body)
+				}
+
+				plain, err := server.DecryptEnvelope(body)
+				if err != nil {
+					t.Fatalf("error not expected: %s", err)
+				}
+				if !bytes.Contains(plain, []byte("<soap:Body><GetOrder xmlns=\"http://example.com/orders\"><orderId>1</orderId></GetOrder></soap:Body>")) {
+					t.Errorf("unexpected decrypted request:\n%s", plain)
+				}
+
+				res, err := server.EncryptEnvelope([]byte(fmt.Sprintf(getOrderResponse, "1", "")))
+				if err != nil {
+					t.Fatalf("error not expected: %s", err)
+				}
+				w.Write(res)
+			})
+			defer ts.Close()
+
+			c, err := NewClient(ts.URL+"?wsdl", WithEncryption(Encryption{Recipient: serverCert, Key: clientKey, Algorithm: algorithm}))
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+			c.PayloadFormat = &PayloadFormat{}
+
+			res, err := c.Call("GetOrder", Params{"orderId": "1"})
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+
+			want := `<GetOrderResponse xmlns="http://example.com/orders"><orderId>1</orderId><status>NEW</status></GetOrderResponse>`
+			if string(res.Body) != want {
+				t.Errorf("unexpected body %s", res.Body)
+			}
+		})
+	}
+}
+
+func TestEncryption_EncryptEnvelope(t *testing.T) {
+	cert, key := newTestCertificate(t, "server")
+	e := &Encryption{Recipient: cert, Key: key}
+
+	tests := []struct {
+		name     string
+		envelope string
+		header   string
+	}{
+		{
+			name:     "no header",
+			envelope: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><a>1</a></s:Body></s:Envelope>`,
+			header:   `<s:Header><wsse:Security xmlns:wsse="` + wsseNamespace + `" s:mustUnderstand="1"><xenc:EncryptedKey`,
+		},
+		{
+			name:     "empty header",
+			envelope: `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Header/><s:Body><a>1</a></s:Body></s:Envelope>`,
+			header:   `<s:Header><wsse:Security`,
+		},
+		{
+			name:     "existing security",
+			envelope: `<Envelope xmlns="http://schemas.xmlsoap.org/soap/envelope/"><Header><x/><wsse:Security xmlns:wsse="` + wsseNamespace + `"><t/></wsse:Security></Header><Body><a>1</a></Body></Envelope>`,
+			header:   `<Header><x/><wsse:Security xmlns:wsse="` + wsseNamespace + `"><t/><xenc:EncryptedKey`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := e.EncryptEnvelope([]byte(tt.envelope))
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+
+			if !bytes.Contains(b, []byte(tt.header)) || bytes.Contains(b, []byte("<a>1</a>")) {
+				t.Errorf("unexpected envelope:\n%s", b)
+			}
+
+			plain, err := e.DecryptEnvelope(b)
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+			if !bytes.Contains(plain, []byte("Body><a>1</a></")) {
+				t.Errorf("unexpected decrypted envelope:\n%s", plain)
+			}
+		})
+	}
+
+	other, otherKey := newTestCertificate(t, "other")
+	b, err := (&Encryption{Recipient: other}).EncryptEnvelope([]byte(tests[0].envelope))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if _, err := e.DecryptEnvelope(b); err == nil {
+		t.Errorf("error expected with the wrong key")
+	}
+	if _, err := (&Encryption{Key: otherKey}).DecryptEnvelope(b); err != nil {
+		t.Errorf("error not expected: %s", err)
+	}
+}
+
+func TestEncryption_Charset(t *testing.T) {
+	clientCert, clientKey := newTestCertificate(t, "client")
+	serverCert, serverKey := newTestCertificate(t, "server")
+	server := &Encryption{Recipient: clientCert, Key: serverKey}
+	latin1 := PayloadFormat{Charset: "ISO-8859-1"}
+
+	var extra string
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !bytes.HasPrefix(body, []byte(`<?xml version="1.0" encoding="ISO-8859-1"?>`)) {
+			t.Errorf("request must be encoded in ISO-8859-1:\n%s", body)
+		}
+
+		body, err := utf8Envelope(body)
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+		plain, err := server.DecryptEnvelope(body)
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+		if !bytes.Contains(plain, []byte("<orderId>ação</orderId>")) {
+			t.Errorf("unexpected decrypted request:\n%s", plain)
+		}
+
+		res, err := server.EncryptEnvelope([]byte(fmt.Sprintf(getOrderResponse, "ação", extra)))
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+		res, err = latin1.encode(res)
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+		w.Write(res)
+	})
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL+"?wsdl", WithCharset("ISO-8859-1"), WithLimits(DecodeLimits{MaxDepth: 16}),
+		WithEncryption(Encryption{Recipient: serverCert, Key: clientKey}))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	res, err := c.Call("GetOrder", Params{"orderId": "ação"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if !strings.Contains(string(res.Body), "<orderId>ação</orderId>") {
+		t.Errorf("unexpected body %s", res.Body)
+	}
+
+	// the limits are checked on the decrypted envelope
+	extra = strings.Repeat("<x>", 20) + strings.Repeat("</x>", 20)
+	var le *LimitError
+	if _, err := c.Call("GetOrder", Params{"orderId": "ação"}); !errors.As(err, &le) || le.Limit != "MaxDepth" {
+		t.Errorf("MaxDepth LimitError expected, got %v", err)
+	}
+}
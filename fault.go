@@ -0,0 +1,208 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+
+	"golang.org/x/net/html/charset"
+)
+
+type faultDetail struct {
+	Text    string        `xml:",chardata"`
+	Inner   []byte        `xml:",innerxml"`
+	Entries []detailEntry `xml:",any"`
+}
+
+// UnmarshalXML decodes a SOAP 1.1 or 1.2 fault, keeping the raw content of detail in DetailXML.
+// The Code of a SOAP 1.2 fault is its Code Value, the Description the first Reason Text
+func (f *Fault) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Code        string      `xml:"faultcode"`
+		Description string      `xml:"faultstring"`
+		Detail      faultDetail `xml:"detail"`
+
+		Code12   string      `xml:"Code>Value"`
+		Reason12 []string    `xml:"Reason>Text"`
+		Detail12 faultDetail `xml:"Detail"`
+	}
+
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+
+	if v.Code == "" && v.Code12 != "" {
+		v.Code, v.Detail = v.Code12, v.Detail12
+		if len(v.Reason12) > 0 {
+			v.Description = v.Reason12[0]
+		}
+	}
+
+	f.Code, f.Description, f.Detail, f.DetailXML = v.Code, v.Description, v.Detail.Text, v.Detail.Inner
+	f.entries = v.Detail.Entries
+	return nil
+}
+
+// detailEntry holds the tokens of an element of the fault detail, names resolved
+// by the decoder of the fault so prefixes declared on the envelope are kept
+type detailEntry []xml.Token
+
+func (e *detailEntry) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*e = detailEntry{start.Copy()}
+	for depth := 1; depth > 0; {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		*e = append(*e, xml.CopyToken(t))
+	}
+
+	return nil
+}
+
+// Token returns the tokens of the entry in turn
+func (e *detailEntry) Token() (xml.Token, error) {
+	if len(*e) == 0 {
+		return nil, io.EOF
+	}
+
+	t := (*e)[0]
+	*e = (*e)[1:]
+	return t, nil
+}
+
+// decodeFault decodes the first element of body into f, body being the content of an
+// envelope element declaring the namespaces of scope
+func decodeFault(body []byte, scope []xml.Attr, f *Fault) error {
+	var b bytes.Buffer
+	b.WriteString("<scope")
+	for _, a := range scope {
+		name := "xmlns"
+		if a.Name.Space == "xmlns" {
+			name += ":" + a.Name.Local
+		}
+		b.WriteString(" " + name + `="`)
+		xml.EscapeText(&b, []byte(a.Value))
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+	b.Write(body)
+	b.WriteString("</scope>")
+
+	d := xml.NewDecoder(&b)
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if depth == 1 {
+				return d.DecodeElement(f, &t)
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// bodyScope returns the namespace declarations of the Envelope and Body elements of
+// the envelope b
+func bodyScope(b []byte) []xml.Attr {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.CharsetReader = charset.NewReaderLabel
+
+	var scope []xml.Attr
+	depth := 0
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			return nil
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			body := depth == 2 && t.Name.Local == "Body"
+			if depth != 1 && !body {
+				continue
+			}
+
+			for _, a := range t.Attr {
+				if a.Name.Space != "xmlns" && (a.Name.Space != "" || a.Name.Local != "xmlns") {
+					continue
+				}
+
+				// declarations of Body override the ones of Envelope
+				for i := range scope {
+					if scope[i].Name == a.Name {
+						scope = append(scope[:i], scope[i+1:]...)
+						break
+					}
+				}
+				scope = append(scope, a)
+			}
+
+			if body {
+				return scope
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// RegisterFaultDetail decodes the fault detail entries named name into new values of
+// the type of v, set as FaultError.Detail. If the type implements error, errors.As
+// finds it in the *FaultError. An empty name.Space matches any namespace. Must be
+// called before the requests
+func (c *Client) RegisterFaultDetail(name xml.Name, v interface{}) {
+	if c.faultDetails == nil {
+		c.faultDetails = map[xml.Name]reflect.Type{}
+	}
+
+	c.faultDetails[name] = reflect.TypeOf(v)
+}
+
+// decodeFaultDetail returns the first entry of detail decoded into its registered type, or nil
+func decodeFaultDetail(types map[xml.Name]reflect.Type, entries []detailEntry) interface{} {
+	for _, e := range entries {
+		name := e[0].(xml.StartElement).Name
+		typ, ok := types[name]
+		if !ok {
+			typ, ok = types[xml.Name{Local: name.Local}]
+		}
+		if !ok {
+			continue
+		}
+
+		// pointer types are decoded into a new value they point to
+		elem := typ
+		if typ.Kind() == reflect.Ptr {
+			elem = typ.Elem()
+		}
+
+		v := reflect.New(elem)
+		if err := xml.NewTokenDecoder(&e).Decode(v.Interface()); err != nil {
+			return nil
+		}
+
+		if typ.Kind() == reflect.Ptr {
+			return v.Interface()
+		}
+		return v.Elem().Interface()
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+type notFoundFault struct {
+	OrderID string `xml:"orderId"`
+}
+
+func (f *notFoundFault) Error() string {
+	return fmt.Sprintf("order %s not found", f.OrderID)
+}
+
+type validationFault struct {
+	Field   string `xml:"field"`
+	Message string `xml:"message"`
+}
+
+func TestClient_RegisterFaultDetail(t *testing.T) {
+	details := map[string]string{
+		"missing": `<detail><ns:NotFoundFault xmlns:ns="http://example.com/orders"><ns:orderId>missing</ns:orderId></ns:NotFoundFault></detail>`,
+		"invalid": `<detail><other/><ValidationFault xmlns="http://example.com/orders"><field>orderId</field><message>too long</message></ValidationFault></detail>`,
+		"text":    `<detail>no details</detail>`,
+		// the prefix is declared on the envelope
+		"scoped": `<detail><ns:NotFoundFault><ns:orderId>scoped</ns:orderId></ns:NotFoundFault></detail>`,
+	}
+
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		for id, detail := range details {
+			if bytes.Contains(body, []byte("<orderId>"+id+"</orderId>")) {
+				fmt.Fprintf(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ns="http://example.com/orders"><soap:Body><soap:Fault>`+
+					`<faultcode>soap:Client</faultcode><faultstring>failed</faultstring>%s</soap:Fault></soap:Body></soap:Envelope>`, detail)
+				return
+			}
+		}
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	c.RegisterFaultDetail(xml.Name{Space: "http://example.com/orders", Local: "NotFoundFault"}, &notFoundFault{})
+	c.RegisterFaultDetail(xml.Name{Local: "ValidationFault"}, validationFault{})
+
+	fault := func(id string) *FaultError {
+		res, err := c.Call("GetOrder", Params{"orderId": id})
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+
+		var f *FaultError
+		if !errors.As(res.Unmarshal(&struct{}{}), &f) {
+			t.Fatalf("fault expected")
+		}
+		return f
+	}
+
+	var nf *notFoundFault
+	if err := error(fault("missing")); !errors.As(err, &nf) || nf.OrderID != "missing" {
+		t.Errorf("unexpected detail %#v", nf)
+	}
+
+	if err := error(fault("scoped")); !errors.As(err, &nf) || nf.OrderID != "scoped" {
+		t.Errorf("unexpected detail %#v", nf)
+	}
+
+	f := fault("invalid")
+	if v, ok := f.Detail.(validationFault); !ok || v.Field != "orderId" || v.Message != "too long" {
+		t.Errorf("unexpected detail %#v", f.Detail)
+	}
+	if !bytes.HasPrefix(f.DetailXML, []byte("<other/>")) {
+		t.Errorf("unexpected raw detail %s", f.DetailXML)
+	}
+
+	if f := fault("text"); f.Detail != "no details" {
+		t.Errorf("unexpected detail %#v", f.Detail)
+	}
+}
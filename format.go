@@ -0,0 +1,161 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// PayloadFormat controls how the request envelope is written
+type PayloadFormat struct {
+	// Indent is repeated for each nesting level, empty writes the envelope on a single line
+	Indent string
+	// XMLDeclaration writes <?xml version="1.0" encoding="..."?> before the envelope,
+	// it's always written for charsets other than UTF-8
+	XMLDeclaration bool
+	// Charset of the envelope, UTF-8 if empty. Any IANA charset known to golang.org/x/text
+	// may be used, such as ISO-8859-1, windows-1252 or IBM037
+	Charset string
+}
+
+// DefaultPayloadFormat is used when neither the Request nor the Client set a PayloadFormat
+var DefaultPayloadFormat = PayloadFormat{Indent: "    "}
+
+// WithCharset encodes the request envelopes in the charset name, the xml declaration
+// is written for charsets other than UTF-8
+func WithCharset(name string) Option {
+	return func(c *Client) error {
+		f := DefaultPayloadFormat
+		if c.PayloadFormat != nil {
+			f = *c.PayloadFormat
+		}
+
+		f.Charset = name
+		if _, err := f.encoding(); err != nil {
+			return err
+		}
+
+		c.PayloadFormat = &f
+		return nil
+	}
+}
+
+// charsetName returns the preferred MIME name of the charset
+func (f PayloadFormat) charsetName() string {
+	if f.Charset == "" {
+		return "UTF-8"
+	}
+
+	e, err := ianaindex.IANA.Encoding(f.Charset)
+	if err != nil || e == nil {
+		return f.Charset
+	}
+
+	if n, err := ianaindex.MIME.Name(e); err == nil {
+		return n
+	}
+	if n, err := ianaindex.IANA.Name(e); err == nil {
+		return n
+	}
+
+	return f.Charset
+}
+
+// encoding returns the encoding of the charset, nil for UTF-8
+func (f PayloadFormat) encoding() (encoding.Encoding, error) {
+	if f.Charset == "" {
+		return nil, nil
+	}
+
+	e, err := ianaindex.IANA.Encoding(f.Charset)
+	if err != nil || e == nil {
+		return nil, fmt.Errorf("unsupported charset %q", f.Charset)
+	}
+
+	if e == unicode.UTF8 {
+		return nil, nil
+	}
+
+	return e, nil
+}
+
+// marshal encodes v as xml following the format
+func (f PayloadFormat) marshal(v interface{}) ([]byte, error) {
+	b, err := f.marshalUTF8(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.encode(b)
+}
+
+// marshalUTF8 encodes v as indented xml in UTF-8, without the xml declaration. The
+// security of the envelope is applied to it before it's encoded in the charset
+func (f PayloadFormat) marshalUTF8(v interface{}) ([]byte, error) {
+	if _, err := f.encoding(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	e.Indent("", f.Indent)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// encode writes the xml declaration before the UTF-8 envelope b and encodes it in the charset
+func (f PayloadFormat) encode(b []byte) ([]byte, error) {
+	enc, err := f.encoding()
+	if err != nil {
+		return nil, err
+	}
+
+	// receivers can't tell the charset of the envelope without the declaration
+	if f.XMLDeclaration || enc != nil {
+		b = append([]byte(fmt.Sprintf("<?xml version=\"1.0\" encoding=\"%s\"?>\n", f.charsetName())), b...)
+	}
+
+	if enc == nil {
+		return b, nil
+	}
+
+	return transcode(enc, b, f.charsetName())
+}
+
+// transcode encodes the UTF-8 text b with enc, reporting the first character
+// the charset name can't represent
+func transcode(enc encoding.Encoding, b []byte, name string) ([]byte, error) {
+	out, err := enc.NewEncoder().Bytes(b)
+	if err == nil {
+		return out, nil
+	}
+
+	e := enc.NewEncoder()
+	for i, r := range string(b) {
+		if _, err := e.String(string(r)); err != nil {
+			return nil, fmt.Errorf("character %q at offset %d can't be encoded in %s", r, i, name)
+		}
+	}
+
+	return nil, fmt.Errorf("envelope can't be encoded in %s: %s", name, err)
+}
+
+// payloadFormat returns the format of the request payload
+func (c *Client) payloadFormat(req *Request) PayloadFormat {
+	if req.PayloadFormat != nil {
+		return *req.PayloadFormat
+	}
+
+	if c.PayloadFormat != nil {
+		return *c.PayloadFormat
+	}
+
+	return DefaultPayloadFormat
+}
@@ -0,0 +1,151 @@
+package gosoap
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPayloadFormat_marshal(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+	p := &process{
+		Client:  &Client{Definitions: d},
+		Request: NewRequest("GetOrder", Params{"orderId": "São Paulo"}),
+	}
+
+	tests := []struct {
+		name    string
+		format  PayloadFormat
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			format: DefaultPayloadFormat,
+			want:   "<soap:Envelope xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xmlns:xsd=\"http://www.w3.org/2001/XMLSchema\" xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">\n    <soap:Body>\n        <GetOrder xmlns=\"http://example.com/orders\">\n            <orderId>São Paulo</orderId>",
+		},
+		{
+			name:   "compact",
+			format: PayloadFormat{},
+			want:   "<soap:Body><GetOrder xmlns=\"http://example.com/orders\"><orderId>São Paulo</orderId></GetOrder></soap:Body></soap:Envelope>",
+		},
+		{
+			name:   "declaration",
+			format: PayloadFormat{Indent: "\t", XMLDeclaration: true},
+			want:   "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<soap:Envelope",
+		},
+		{
+			name:   "latin1",
+			format: PayloadFormat{XMLDeclaration: true, Charset: "iso-8859-1"},
+			want:   "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<soap:Envelope",
+		},
+		{
+			name:   "windows-1252",
+			format: PayloadFormat{Charset: "Windows-1252"},
+			want:   "<?xml version=\"1.0\" encoding=\"windows-1252\"?>\n<soap:Envelope",
+		},
+		{
+			name:    "unsupported charset",
+			format:  PayloadFormat{Charset: "EBCDIC"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.format.marshal(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !strings.Contains(string(b), tt.want) {
+				t.Errorf("payload must contain %q, got:\n%s", tt.want, b)
+			}
+		})
+	}
+
+	b, _ := PayloadFormat{Charset: "ISO-8859-1"}.marshal(p)
+	if !strings.Contains(string(b), "<orderId>S\xe3o Paulo</orderId>") {
+		t.Errorf("payload must be encoded in ISO-8859-1, got:\n%s", b)
+	}
+
+	p.Request.Params = Params{"orderId": "€"}
+	if _, err := (PayloadFormat{Charset: "ISO-8859-1"}).marshal(p); err == nil {
+		t.Errorf("error expected for characters not in ISO-8859-1")
+	}
+}
+
+func TestClient_PayloadFormat(t *testing.T) {
+	var contentType, body string
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		contentType, body = r.Header.Get("Content-Type"), string(b)
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`)
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PayloadFormat = &PayloadFormat{Charset: "ISO-8859-1"}
+	c.ValidateMessages = true
+
+	if _, err := c.Call("GetOrder", Params{"orderId": "ação"}); err == nil {
+		t.Errorf("empty response must fail validation")
+	}
+
+	if contentType != "text/xml;charset=ISO-8859-1" || !strings.HasPrefix(body, `<?xml version="1.0" encoding="ISO-8859-1"?>`) ||
+		!strings.Contains(body, "<orderId>a\xe7\xe3o</orderId>") {
+		t.Errorf("unexpected request %q:\n%s", contentType, body)
+	}
+
+	req := NewRequest("GetOrder", Params{"orderId": "1"})
+	req.PayloadFormat = &PayloadFormat{}
+	c.Do(req)
+
+	if contentType != "text/xml;charset=UTF-8" || strings.Contains(body, "\n") {
+		t.Errorf("request format must take precedence, got %q:\n%s", contentType, body)
+	}
+}
+
+func TestWithCharset(t *testing.T) {
+	var contentType string
+	var body []byte
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, getOrderResponse, "1", "")
+	})
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL+"?wsdl", WithCharset("IBM037"))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if _, err := c.Call("GetOrder", Params{"orderId": "ab"}); err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	// "<?xml" and "ab" in EBCDIC
+	if contentType != "text/xml;charset=IBM037" || !bytes.HasPrefix(body, []byte{0x4c, 0x6f, 0xa7, 0x94, 0x93}) || !bytes.Contains(body, []byte{0x6e, 0x81, 0x82, 0x4c}) {
+		t.Errorf("unexpected request %q: % x", contentType, body)
+	}
+
+	c, err = NewClient(ts.URL+"?wsdl", WithCharset("ISO-8859-1"))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	_, err = c.Call("GetOrder", Params{"orderId": "10 €"})
+	if err == nil || !strings.Contains(err.Error(), `character '€' at offset`) || !strings.Contains(err.Error(), "ISO-8859-1") {
+		t.Errorf("unrepresentable character error expected, got %v", err)
+	}
+
+	if _, err := NewClient(ts.URL+"?wsdl", WithCharset("EBCDIC")); err == nil {
+		t.Errorf("error expected")
+	}
+}
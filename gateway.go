@@ -0,0 +1,153 @@
+package gosoap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Gateway is an http.Handler exposing each operation of the Client wsdl as
+// POST /{operation}, taking and returning JSON converted with the wsdl types
+type Gateway struct {
+	Client *Client
+	// ServeOpenAPI enables GET /openapi.json with the OpenAPI 3 document of the operations
+	ServeOpenAPI bool
+	// MaxBodySize of the JSON requests, DefaultGatewayMaxBodySize if zero
+	MaxBodySize int64
+	// ErrorLog logs the errors not returned to the http clients, the standard logger if nil
+	ErrorLog *log.Logger
+}
+
+// DefaultGatewayMaxBodySize is the size of the JSON requests accepted by default
+const DefaultGatewayMaxBodySize = 1 << 20
+
+// NewGateway return new *Gateway calling the operations with c
+func NewGateway(c *Client) *Gateway {
+	return &Gateway{Client: c}
+}
+
+// GatewayError is the JSON body of the gateway error responses
+type GatewayError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := g.Client.LoadDefinitions(); err != nil {
+		g.logf("gosoap: gateway wsdl: %s", err)
+		writeGatewayError(w, http.StatusBadGateway, &GatewayError{Code: "wsdl", Message: "wsdl unavailable"})
+		return
+	}
+
+	name := strings.Trim(r.URL.Path, "/")
+
+	if g.ServeOpenAPI && name == "openapi.json" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeGatewayError(w, http.StatusMethodNotAllowed, &GatewayError{Code: "method", Message: "method not allowed"})
+			return
+		}
+
+		doc, err := g.Client.Definitions.OpenAPI()
+		if err != nil {
+			g.logf("gosoap: gateway openapi: %s", err)
+			writeGatewayError(w, http.StatusInternalServerError, &GatewayError{Code: "openapi", Message: "openapi document unavailable"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+		return
+	}
+
+	if g.Client.Definitions.operation(name) == nil {
+		writeGatewayError(w, http.StatusNotFound, &GatewayError{Code: "operation", Message: fmt.Sprintf("operation %q not found", name)})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeGatewayError(w, http.StatusMethodNotAllowed, &GatewayError{Code: "method", Message: "method not allowed"})
+		return
+	}
+
+	max := g.MaxBodySize
+	if max == 0 {
+		max = DefaultGatewayMaxBodySize
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		writeGatewayError(w, http.StatusRequestEntityTooLarge, &GatewayError{Code: "request", Message: fmt.Sprintf("request body larger than %d bytes", max)})
+		return
+	}
+
+	if len(strings.TrimSpace(string(data))) == 0 {
+		data = []byte("{}")
+	}
+
+	p, err := g.Client.Definitions.JSONToParams(name, data)
+	if err != nil {
+		writeGatewayError(w, http.StatusBadRequest, &GatewayError{Code: "request", Message: err.Error()})
+		return
+	}
+
+	res, err := g.Client.CallArray(name, p)
+	if err == nil {
+		err = res.fault()
+	}
+
+	var fault *FaultError
+	if errors.As(err, &fault) {
+		writeGatewayError(w, faultStatus(fault), &GatewayError{Code: fault.Code, Message: fault.Description, Detail: fault.Detail})
+		return
+	}
+
+	if err != nil {
+		g.logf("gosoap: gateway %s: %s", name, err)
+		writeGatewayError(w, http.StatusBadGateway, &GatewayError{Code: "upstream", Message: "upstream service error"})
+		return
+	}
+
+	b, err := g.Client.Definitions.BodyToJSON(name, res.Body)
+	if err != nil {
+		g.logf("gosoap: gateway %s response: %s", name, err)
+		writeGatewayError(w, http.StatusBadGateway, &GatewayError{Code: "response", Message: "invalid upstream response"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (g *Gateway) logf(format string, v ...interface{}) {
+	if g.ErrorLog != nil {
+		g.ErrorLog.Printf(format, v...)
+		return
+	}
+
+	log.Printf(format, v...)
+}
+
+// faultStatus maps client faults to 400 Bad Request and any other to 502 Bad Gateway
+func faultStatus(f *FaultError) int {
+	code := localName(f.Code)
+	if code == "Client" || code == "Sender" || strings.HasPrefix(code, "Client.") {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusBadGateway
+}
+
+func writeGatewayError(w http.ResponseWriter, status int, e *GatewayError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error *GatewayError `json:"error"`
+	}{e})
+}
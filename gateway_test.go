@@ -0,0 +1,112 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGateway_ServeHTTP(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "<orderId>broken</orderId>") {
+			http.Error(w, "stack trace of db01.internal", http.StatusInternalServerError)
+			return
+		}
+		if strings.Contains(string(body), "<orderId>missing</orderId>") {
+			fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Client</faultcode><faultstring>order not found</faultstring>`+
+				`</soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		}
+
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<GetOrderResponse xmlns="http://example.com/orders"><orderId>7</orderId><status>NEW</status></GetOrderResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logged bytes.Buffer
+	g := NewGateway(c)
+	g.ServeOpenAPI = true
+	g.MaxBodySize = 64
+	g.ErrorLog = log.New(&logged, "", 0)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/GetOrder", `{"orderId": "7"}`, http.StatusOK, `{"GetOrderResponse":{"orderId":"7","status":"NEW"}}`},
+		{"POST", "/GetOrder", `{"orderId": "missing"}`, http.StatusBadRequest, `"code":"soap:Client","message":"order not found"`},
+		{"POST", "/GetOrder", `{"orderId": [1, 2]}`, http.StatusBadRequest, `"code":"request"`},
+		{"POST", "/DeleteOrder", `{}`, http.StatusNotFound, `"code":"operation"`},
+		{"GET", "/GetOrder", ``, http.StatusMethodNotAllowed, `"code":"method"`},
+		{"GET", "/openapi.json", ``, http.StatusOK, `"operationId":"CreateOrder"`},
+		{"POST", "/GetOrder", `{"orderId": "` + strings.Repeat("1", 64) + `"}`, http.StatusRequestEntityTooLarge, `"message":"request body larger than 64 bytes"`},
+		{"POST", "/GetOrder", `{"orderId": "broken"}`, http.StatusBadGateway, `{"error":{"code":"upstream","message":"upstream service error"}}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s %s: body %s must contain %s", tt.method, tt.path, w.Body.String(), tt.want)
+		}
+	}
+
+	if !strings.Contains(logged.String(), "gosoap: gateway GetOrder: ") {
+		t.Errorf("upstream error must be logged, got %q", logged.String())
+	}
+}
+
+func TestDefinitions_OpenAPI(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	b, err := d.OpenAPI()
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	var doc struct {
+		Paths map[string]struct {
+			Post struct {
+				RequestBody struct {
+					Content map[string]struct {
+						Schema struct {
+							Required   []string
+							Properties map[string]map[string]interface{}
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	s := doc.Paths["/CreateOrder"].Post.RequestBody.Content["application/json"].Schema
+	if strings.Join(s.Required, ",") != "customerId,address,item" {
+		t.Errorf("unexpected required: %v", s.Required)
+	}
+
+	if s.Properties["item"]["type"] != "array" || s.Properties["express"]["type"] != "boolean" {
+		t.Errorf("unexpected properties: %v", s.Properties)
+	}
+}
@@ -2,7 +2,8 @@ module github.com/tiaguinho/gosoap
 
 require (
 	golang.org/x/net v0.0.0-20190125091013-d26f9f9a57f3
-	golang.org/x/text v0.3.0 // indirect
+	golang.org/x/text v0.3.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
-go 1.13
+go 1.16
@@ -0,0 +1,192 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+)
+
+// RequestHook may change a request before it's marshalled, e.g. to add a param to
+// every request. It gets a copy of the request whose params may be changed in place
+type RequestHook func(*Request) error
+
+// BodyHook transforms the tokens of a response body read from r. Tokens are read
+// with RawToken, the names keep their prefix in Space as written
+type BodyHook func(r xml.TokenReader) xml.TokenReader
+
+type requestHook struct {
+	ops map[string]bool
+	fn  RequestHook
+}
+
+type bodyHook struct {
+	ops map[string]bool
+	fn  BodyHook
+}
+
+// OnRequest registers h for the operations ops, or for all of them if none is given.
+// Hooks run in the order they're registered. Must be called before the requests
+func (c *Client) OnRequest(h RequestHook, ops ...string) {
+	c.requestHooks = append(c.requestHooks, requestHook{ops: operationSet(ops), fn: h})
+}
+
+// OnResponseBody registers h for the operations ops, or for all of them if none is given.
+// Hooks run in the order they're registered. Must be called before the requests
+func (c *Client) OnResponseBody(h BodyHook, ops ...string) {
+	c.bodyHooks = append(c.bodyHooks, bodyHook{ops: operationSet(ops), fn: h})
+}
+
+func operationSet(ops []string) map[string]bool {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	s := make(map[string]bool, len(ops))
+	for _, o := range ops {
+		s[o] = true
+	}
+
+	return s
+}
+
+// runRequestHooks returns a copy of req changed by the hooks of its operation
+func (c *Client) runRequestHooks(req *Request) (*Request, error) {
+	if len(c.requestHooks) == 0 {
+		return req, nil
+	}
+
+	r := *req
+	if req.Params != nil {
+		r.Params = make(Params, len(req.Params))
+		for k, v := range req.Params {
+			r.Params[k] = v
+		}
+	}
+	if req.ArrayParams != nil {
+		r.ArrayParams = append(ArrayParams(nil), req.ArrayParams...)
+	}
+
+	for _, h := range c.requestHooks {
+		if h.ops == nil || h.ops[req.Method] {
+			if err := h.fn(&r); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &r, nil
+}
+
+// runBodyHooks returns the body transformed by the hooks of the operation m
+func (c *Client) runBodyHooks(m string, body []byte) ([]byte, error) {
+	var hooks []BodyHook
+	for _, h := range c.bodyHooks {
+		if h.ops == nil || h.ops[m] {
+			hooks = append(hooks, h.fn)
+		}
+	}
+
+	if len(hooks) == 0 {
+		return body, nil
+	}
+
+	return transformTokens(body, "", hooks...)
+}
+
+// transformTokens reads the raw tokens of the xml b through the hooks and writes them
+// back, indented with indent if not empty
+func transformTokens(b []byte, indent string, hooks ...BodyHook) ([]byte, error) {
+	var r xml.TokenReader = rawTokenReader{xml.NewDecoder(bytes.NewReader(b))}
+	for _, h := range hooks {
+		r = h(r)
+	}
+
+	var out bytes.Buffer
+	e := xml.NewEncoder(&out)
+	e.Indent("", indent)
+	for {
+		t, err := r.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tt := t.(type) {
+		case xml.StartElement:
+			s := xml.StartElement{Name: rawName(tt.Name)}
+			for _, a := range tt.Attr {
+				s.Attr = append(s.Attr, xml.Attr{Name: rawName(a.Name), Value: a.Value})
+			}
+			t = s
+		case xml.EndElement:
+			t = xml.EndElement{Name: rawName(tt.Name)}
+		case xml.ProcInst:
+			if tt.Target == "xml" {
+				continue
+			}
+		}
+
+		if err := e.EncodeToken(t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// rawTokenReader reads the raw tokens of d
+type rawTokenReader struct {
+	d *xml.Decoder
+}
+
+func (r rawTokenReader) Token() (xml.Token, error) {
+	return r.d.RawToken()
+}
+
+// StripElement returns a BodyHook removing the elements with the local name,
+// their content is kept in their place
+func StripElement(local string) BodyHook {
+	return func(r xml.TokenReader) xml.TokenReader {
+		return &stripReader{r: r, local: local}
+	}
+}
+
+type stripReader struct {
+	r        xml.TokenReader
+	local    string
+	stripped []bool
+}
+
+func (s *stripReader) Token() (xml.Token, error) {
+	for {
+		t, err := s.r.Token()
+		if err != nil {
+			return t, err
+		}
+
+		switch tt := t.(type) {
+		case xml.StartElement:
+			strip := tt.Name.Local == s.local
+			s.stripped = append(s.stripped, strip)
+			if strip {
+				continue
+			}
+		case xml.EndElement:
+			if n := len(s.stripped); n > 0 {
+				strip := s.stripped[n-1]
+				s.stripped = s.stripped[:n-1]
+				if strip {
+					continue
+				}
+			}
+		}
+
+		return t, nil
+	}
+}
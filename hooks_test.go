@@ -0,0 +1,74 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestClient_Hooks(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !bytes.Contains(body, []byte("<tenant>acme</tenant><orderId>1</orderId>")) {
+			t.Errorf("tenant expected in request %s", body)
+		}
+		if strings.HasSuffix(r.Header.Get("SOAPAction"), "/GetOrder") && !bytes.Contains(body, []byte("<version>2</version>")) {
+			t.Errorf("version expected in request %s", body)
+		}
+
+		w.Write([]byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>` +
+			`<ns:GetOrderResponse xmlns:ns="http://example.com/orders"><ns:result><ns:orderId>1</ns:orderId><!--kept--><ns:status a="&amp;">NEW</ns:status></ns:result></ns:GetOrderResponse>` +
+			`</soap:Body></soap:Envelope>`))
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	c.PayloadFormat = &PayloadFormat{}
+
+	c.OnRequest(func(r *Request) error {
+		r.ArrayParams = append(ArrayParams{{"tenant", "acme"}}, r.ArrayParams...)
+		return nil
+	})
+	c.OnRequest(func(r *Request) error {
+		r.ArrayParams = append(r.ArrayParams, [2]interface{}{"version", "2"})
+		return nil
+	}, "GetOrder")
+	c.OnRequest(func(r *Request) error {
+		return errors.New("read only")
+	}, "CreateOrder")
+	c.OnResponseBody(StripElement("result"), "GetOrder")
+
+	params := ArrayParams{{"orderId", "1"}}
+	res, err := c.CallArray("GetOrder", params)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := `<ns:GetOrderResponse xmlns:ns="http://example.com/orders"><ns:orderId>1</ns:orderId><!--kept--><ns:status a="&amp;">NEW</ns:status></ns:GetOrderResponse>`
+	if string(res.Body) != want {
+		t.Errorf("unexpected body\n%s\nwant\n%s", res.Body, want)
+	}
+
+	var v struct {
+		XMLName xml.Name `xml:"http://example.com/orders GetOrderResponse"`
+		OrderID string   `xml:"orderId"`
+	}
+	if err := res.Unmarshal(&v); err != nil || v.OrderID != "1" {
+		t.Errorf("unexpected response %+v: %v", v, err)
+	}
+
+	if len(params) != 1 {
+		t.Errorf("request params must not be changed, got %v", params)
+	}
+
+	if _, err := c.CallArray("CreateOrder", ArrayParams{{"orderId", "1"}}); err == nil || err.Error() != "read only" {
+		t.Errorf("hook error expected, got %v", err)
+	}
+}
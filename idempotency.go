@@ -0,0 +1,164 @@
+package gosoap
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Idempotency attaches a message ID to each logical call, reused when the call is
+// retried so the service can detect duplicates
+type Idempotency struct {
+	// Header is the http header holding the ID, Idempotency-Key if empty
+	Header string
+	// Store keeps the IDs of the requests with an IdempotencyKey until they get a
+	// response, so a call retried after a restart reuses its ID. Optional
+	Store MessageIDStore
+	// NewID returns a new message ID, a urn:uuid of a random UUID if nil
+	NewID func() (string, error)
+	// Retries of a request failing to be sent, with the same ID
+	Retries int
+	// RetryDelay between the retries
+	RetryDelay time.Duration
+}
+
+// MessageIDStore keeps the message IDs of the calls in flight by their IdempotencyKey
+type MessageIDStore interface {
+	// PutIfAbsent stores id for key unless key already has an ID, and returns the ID
+	// of key. It must be atomic, so concurrent calls with the same key get the same ID
+	PutIfAbsent(key, id string) (string, error)
+	Delete(key string) error
+}
+
+// WithIdempotency attaches message IDs to the requests as configured by i
+func WithIdempotency(i Idempotency) Option {
+	return func(c *Client) error {
+		c.Idempotency = &i
+		return nil
+	}
+}
+
+// assign sets the MessageID of req, unless it already has one
+func (i *Idempotency) assign(req *Request) error {
+	if i == nil || req.MessageID != "" {
+		return nil
+	}
+
+	newID := i.NewID
+	if newID == nil {
+		newID = newMessageID
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	if req.IdempotencyKey != "" && i.Store != nil {
+		if id, err = i.Store.PutIfAbsent(req.IdempotencyKey, id); err != nil {
+			return err
+		}
+	}
+
+	req.MessageID = id
+	return nil
+}
+
+// withHeader returns a copy of req with the message ID header
+func (i *Idempotency) withHeader(req *Request) *Request {
+	if i == nil || req.MessageID == "" {
+		return req
+	}
+
+	name := i.Header
+	if name == "" {
+		name = "Idempotency-Key"
+	}
+
+	r := *req
+	r.HTTPHeader = req.HTTPHeader.Clone()
+	if r.HTTPHeader == nil {
+		r.HTTPHeader = http.Header{}
+	}
+	r.HTTPHeader.Set(name, req.MessageID)
+
+	return &r
+}
+
+// send calls send, retrying on failures
+func (i *Idempotency) send(send func(string) ([]byte, error), url string) ([]byte, error) {
+	b, err := send(url)
+	if i == nil {
+		return b, err
+	}
+
+	for n := 0; err != nil && n < i.Retries; n++ {
+		time.Sleep(i.RetryDelay)
+		b, err = send(url)
+	}
+
+	return b, err
+}
+
+// done forgets the ID of req once it got a response
+func (i *Idempotency) done(req *Request) error {
+	if i == nil || i.Store == nil || req.IdempotencyKey == "" {
+		return nil
+	}
+
+	return i.Store.Delete(req.IdempotencyKey)
+}
+
+// newMessageID returns a urn:uuid of a random UUID
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// MemoryMessageIDStore is a MessageIDStore in memory, IDs don't survive restarts
+type MemoryMessageIDStore struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// NewMemoryMessageIDStore return new *MemoryMessageIDStore
+func NewMemoryMessageIDStore() *MemoryMessageIDStore {
+	return &MemoryMessageIDStore{ids: map[string]string{}}
+}
+
+// Get returns the ID of key, empty if there's none
+func (s *MemoryMessageIDStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ids[key], nil
+}
+
+func (s *MemoryMessageIDStore) PutIfAbsent(key, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.ids[key]; ok {
+		return old, nil
+	}
+
+	s.ids[key] = id
+	return id, nil
+}
+
+func (s *MemoryMessageIDStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.ids, key)
+	return nil
+}
@@ -0,0 +1,103 @@
+package gosoap
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestIdempotency(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", nil)
+	defer ts.Close()
+
+	var (
+		ids   []string
+		fails int
+	)
+
+	store := NewMemoryMessageIDStore()
+	c, err := NewClient(ts.URL+"?wsdl",
+		WithIdempotency(Idempotency{Header: "X-Message-ID", Store: store, Retries: 2}),
+		WithTransport(TransportFunc(func(m *Message) ([]byte, error) {
+			ids = append(ids, m.Header.Get("X-Message-ID"))
+			if fails > 0 {
+				fails--
+				return nil, errors.New("connection reset")
+			}
+			return []byte(fmt.Sprintf(getOrderResponse, "1", "")), nil
+		})),
+	)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	// retried with the same ID
+	fails = 2
+	req := NewRequest("GetOrder", Params{"orderId": "1"})
+	if _, err := c.Do(req); err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if !regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(req.MessageID) {
+		t.Errorf("unexpected message id %q", req.MessageID)
+	}
+	if len(ids) != 3 || ids[0] != req.MessageID || ids[1] != req.MessageID || ids[2] != req.MessageID {
+		t.Errorf("same id expected on each attempt, got %v", ids)
+	}
+	if req.HTTPHeader != nil {
+		t.Errorf("request header must not be changed")
+	}
+
+	// a new logical call gets a new ID
+	ids = nil
+	if _, err := c.Call("GetOrder", Params{"orderId": "1"}); err != nil || len(ids) != 1 || ids[0] == req.MessageID {
+		t.Errorf("new id expected, got %v: %v", ids, err)
+	}
+
+	// the ID of a keyed call failing is kept until it gets a response
+	ids, fails = nil, 3
+	first := &Request{Method: "GetOrder", Params: Params{"orderId": "1"}, IdempotencyKey: "payment-1"}
+	if _, err := c.Do(first); err == nil {
+		t.Fatalf("error expected")
+	}
+	if id, _ := store.Get("payment-1"); id != first.MessageID {
+		t.Errorf("id must be stored, got %q", id)
+	}
+
+	retry := &Request{Method: "GetOrder", Params: Params{"orderId": "1"}, IdempotencyKey: "payment-1"}
+	if _, err := c.Do(retry); err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if retry.MessageID != first.MessageID || ids[len(ids)-1] != first.MessageID {
+		t.Errorf("stored id expected, got %q, sent %v", retry.MessageID, ids)
+	}
+	if id, _ := store.Get("payment-1"); id != "" {
+		t.Errorf("id must be removed once answered, got %q", id)
+	}
+}
+
+func TestIdempotency_ConcurrentKey(t *testing.T) {
+	i := &Idempotency{Store: NewMemoryMessageIDStore()}
+
+	var wg sync.WaitGroup
+	reqs := make([]*Request, 20)
+	for n := range reqs {
+		reqs[n] = &Request{Method: "Pay", IdempotencyKey: "payment-1"}
+		wg.Add(1)
+		go func(r *Request) {
+			defer wg.Done()
+			if err := i.assign(r); err != nil {
+				t.Errorf("error not expected: %s", err)
+			}
+		}(reqs[n])
+	}
+	wg.Wait()
+
+	for _, r := range reqs {
+		if r.MessageID == "" || r.MessageID != reqs[0].MessageID {
+			t.Fatalf("same id expected for the key, got %q and %q", r.MessageID, reqs[0].MessageID)
+		}
+	}
+}
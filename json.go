@@ -0,0 +1,300 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// CallJSON converts the JSON object data to the input of the operation m, calls it
+// and converts the response body back to JSON. Both conversions follow the wsdl types.
+// A soap fault is returned as *FaultError
+func (c *Client) CallJSON(m string, data []byte) ([]byte, error) {
+	if err := c.LoadDefinitions(); err != nil {
+		return nil, err
+	}
+
+	p, err := c.Definitions.JSONToParams(m, data)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.CallArray(m, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := res.fault(); err != nil {
+		return nil, err
+	}
+
+	return c.Definitions.BodyToJSON(m, res.Body)
+}
+
+// JSONToParams maps the JSON object data to the input element of the operation.
+// Elements are ordered as declared in the schema, arrays become repeated elements
+// and numbers and booleans are checked against and formatted by the element type
+func (wsdl *wsdlDefinitions) JSONToParams(operation string, data []byte) (ArrayParams, error) {
+	s, err := wsdl.InputSchema(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %s", err)
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: JSON object expected", s.Name)
+	}
+
+	return jsonToParams(s, obj, s.Name)
+}
+
+// BodyToJSON converts the response body of the operation to a JSON object. Repeated
+// elements become arrays, numeric and boolean elements become JSON numbers and booleans.
+// Elements unknown to the schema are kept as strings or objects
+func (wsdl *wsdlDefinitions) BodyToJSON(operation string, body []byte) ([]byte, error) {
+	s, err := wsdl.OutputSchema(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &SchemaElement{MinOccurs: 1, MaxOccurs: 1, Children: []*SchemaElement{s}}
+
+	d := xml.NewDecoder(bytes.NewReader(body))
+	m := map[string]interface{}{}
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if t, ok := t.(xml.StartElement); ok {
+			if err := childToJSON(d, root, t, m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return json.Marshal(m)
+}
+
+func jsonToParams(s *SchemaElement, obj map[string]interface{}, path string) (ArrayParams, error) {
+	for k := range obj {
+		if s.Child(k) == nil {
+			return nil, fmt.Errorf("%s/%s: element not declared in schema", path, k)
+		}
+	}
+
+	p := ArrayParams{}
+	for _, c := range s.Children {
+		v, ok := obj[c.Name]
+		if !ok || v == nil {
+			continue
+		}
+
+		items, isList := v.([]interface{})
+		if !isList {
+			items = []interface{}{v}
+		} else if !c.IsRepeated() {
+			return nil, fmt.Errorf("%s/%s: element is not repeated, array not allowed", path, c.Name)
+		}
+
+		for _, item := range items {
+			val, err := jsonToValue(c, item, path+"/"+c.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			p = append(p, [2]interface{}{c.Name, val})
+		}
+	}
+
+	return p, nil
+}
+
+func jsonToValue(s *SchemaElement, v interface{}, path string) (interface{}, error) {
+	if len(s.Children) > 0 {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: JSON object expected", path)
+		}
+
+		return jsonToParams(s, obj, path)
+	}
+
+	var str string
+	switch v := v.(type) {
+	case string:
+		str = v
+	case json.Number:
+		str = v.String()
+	case bool:
+		str = fmt.Sprint(v)
+	default:
+		return nil, fmt.Errorf("%s: element %s expects a simple value", path, s.Type)
+	}
+
+	switch xsdKind(s.Type) {
+	case kindInteger:
+		// integers are exact, 1e3 or 2.0 are accepted as they hold an integer
+		if _, ok := new(big.Int).SetString(str, 10); ok {
+			break
+		}
+		n, ok := new(big.Rat).SetString(str)
+		if !ok || !n.IsInt() || strings.Contains(str, "/") {
+			return nil, fmt.Errorf("%s: %q is not a valid %s", path, str, s.Type)
+		}
+		str = n.Num().String()
+	case kindDecimal:
+		n, ok := new(big.Float).SetString(str)
+		if !ok {
+			return nil, fmt.Errorf("%s: %q is not a valid %s", path, str, s.Type)
+		}
+		if strings.ContainsAny(str, "eE") {
+			str = n.Text('f', -1)
+		}
+	case kindBoolean:
+		if str != "true" && str != "false" && str != "1" && str != "0" {
+			return nil, fmt.Errorf("%s: %q is not a valid %s", path, str, s.Type)
+		}
+	}
+
+	return str, nil
+}
+
+// elementToJSON reads the content of the current element up to its end
+func elementToJSON(d *xml.Decoder, s *SchemaElement) (interface{}, error) {
+	var (
+		text     strings.Builder
+		children map[string]interface{}
+	)
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			if children == nil {
+				children = map[string]interface{}{}
+			}
+			if err := childToJSON(d, s, t, children); err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			if children != nil {
+				return children, nil
+			}
+			return typedValue(s.Type, strings.TrimSpace(text.String())), nil
+		}
+	}
+}
+
+// childToJSON reads the child element started by t into m
+func childToJSON(d *xml.Decoder, s *SchemaElement, t xml.StartElement, m map[string]interface{}) error {
+	c := s.Child(t.Name.Local)
+	if c == nil {
+		c = &SchemaElement{Name: t.Name.Local, MinOccurs: 1, MaxOccurs: 1}
+	}
+
+	v, err := elementToJSON(d, c)
+	if err != nil {
+		return err
+	}
+
+	switch cur := m[c.Name].(type) {
+	case nil:
+		if c.IsRepeated() {
+			v = []interface{}{v}
+		}
+		m[c.Name] = v
+	case []interface{}:
+		m[c.Name] = append(cur, v)
+	default:
+		m[c.Name] = []interface{}{cur, v}
+	}
+
+	return nil
+}
+
+func typedValue(t, v string) interface{} {
+	switch xsdKind(t) {
+	case kindInteger, kindDecimal:
+		if n, ok := jsonNumber(v); ok {
+			return n
+		}
+	case kindBoolean:
+		switch v {
+		case "true", "1":
+			return true
+		case "false", "0":
+			return false
+		}
+	}
+
+	return v
+}
+
+var xsdNumber = regexp.MustCompile(`^([+-]?)([0-9]*)(?:\.([0-9]*))?([eE][+-]?[0-9]+)?$`)
+
+// jsonNumber rewrites the xml schema number v, such as +1.5, .5, 1. or 007, as a JSON number
+func jsonNumber(v string) (json.Number, bool) {
+	m := xsdNumber.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil || m[2]+m[3] == "" {
+		return "", false
+	}
+
+	n := strings.TrimLeft(m[2], "0")
+	if n == "" {
+		n = "0"
+	}
+	if m[1] == "-" {
+		n = "-" + n
+	}
+	if m[3] != "" {
+		n += "." + m[3]
+	}
+
+	return json.Number(n + m[4]), true
+}
+
+const (
+	kindString = iota
+	kindInteger
+	kindDecimal
+	kindBoolean
+)
+
+// xsdKind groups the builtin xml schema types by their JSON representation
+func xsdKind(t string) int {
+	switch localName(t) {
+	case "int", "integer", "long", "short", "byte", "unsignedInt", "unsignedLong", "unsignedShort", "unsignedByte",
+		"nonNegativeInteger", "positiveInteger", "negativeInteger", "nonPositiveInteger":
+		return kindInteger
+	case "decimal", "float", "double":
+		return kindDecimal
+	case "boolean":
+		return kindBoolean
+	}
+
+	return kindString
+}
@@ -0,0 +1,139 @@
+package gosoap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDefinitions_JSONToParams(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	p, err := d.JSONToParams("CreateOrder", []byte(`{
+		"note": "leave at the door",
+		"item": [{"sku": "ABC-0001", "quantity": 2}, {"sku": "ABC-0002", "quantity": 1e1, "price": 1.5}],
+		"address": {"street": "Main", "city": "Lisbon"},
+		"customerId": 42,
+		"express": true
+	}`))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := ArrayParams{
+		{"customerId", "42"},
+		{"address", ArrayParams{{"street", "Main"}, {"city", "Lisbon"}}},
+		{"item", ArrayParams{{"sku", "ABC-0001"}, {"quantity", "2"}}},
+		{"item", ArrayParams{{"sku", "ABC-0002"}, {"quantity", "10"}, {"price", "1.5"}}},
+		{"express", "true"},
+		{"note", "leave at the door"},
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %v, want %v", p, want)
+	}
+
+	for _, data := range []string{
+		`{"customerId": "1", "unknown": "x"}`,
+		`{"customerId": ["1", "2"]}`,
+		`{"item": {"quantity": 1.5}}`,
+		`{"express": "yes"}`,
+		`{"address": "Main"}`,
+		`[]`,
+	} {
+		if _, err := d.JSONToParams("CreateOrder", []byte(data)); err == nil {
+			t.Errorf("error expected for %s", data)
+		}
+	}
+}
+
+func TestJSONInteger(t *testing.T) {
+	s := &SchemaElement{Name: "n", Type: "xs:integer"}
+	for v, want := range map[string]string{"18446744073709551617": "18446744073709551617", "-18446744073709551617": "-18446744073709551617", "1e20": "100000000000000000000", "2.0": "2"} {
+		got, err := jsonToValue(s, json.Number(v), "n")
+		if err != nil || got != want {
+			t.Errorf("%s: got %v, want %s: %v", v, got, want, err)
+		}
+	}
+
+	for _, v := range []string{"18446744073709551617.5", "1e-1", "4/2", "x"} {
+		if got, err := jsonToValue(s, v, "n"); err == nil {
+			t.Errorf("%s: error expected, got %v", v, got)
+		}
+	}
+}
+
+func TestDefinitions_BodyToJSON(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	b, err := d.BodyToJSON("CreateOrder", []byte(`
+		<CreateOrderResponse xmlns="http://example.com/orders">
+			<orderId>A1</orderId>
+			<status>NEW</status>
+			<total>12.50</total>
+			<item><sku>ABC-0001</sku><quantity>2</quantity></item>
+			<extra>x</extra>
+		</CreateOrderResponse>`))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := `{"CreateOrderResponse":{"extra":"x","item":[{"quantity":2,"sku":"ABC-0001"}],"orderId":"A1","status":"NEW","total":12.50}}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestJSONNumber(t *testing.T) {
+	for v, want := range map[string]string{"+1.5": "1.5", ".5": "0.5", "1.": "1", "-007": "-7", "12.50": "12.50", "-1E+3": "-1E+3", " 4 ": "4"} {
+		if n, ok := jsonNumber(v); !ok || string(n) != want {
+			t.Errorf("%q: got %q, want %q", v, n, want)
+		}
+	}
+
+	for _, v := range []string{"", ".", "+", "INF", "NaN", "0x10", "1.2.3"} {
+		if n, ok := jsonNumber(v); ok {
+			t.Errorf("%q must be kept as a string, got %q", v, n)
+		}
+	}
+
+	d := loadTestDefinitions(t, "orders.wsdl")
+	b, err := d.BodyToJSON("CreateOrder", []byte(`<CreateOrderResponse xmlns="http://example.com/orders"><total>+.5</total></CreateOrderResponse>`))
+	if err != nil || string(b) != `{"CreateOrderResponse":{"total":0.5}}` {
+		t.Errorf("unexpected JSON %s: %v", b, err)
+	}
+}
+
+func TestClient_CallJSON(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		diffs, err := DiffXML([]byte(`<Envelope xmlns="http://schemas.xmlsoap.org/soap/envelope/"><Body>`+
+			`<CreateOrder xmlns="http://example.com/orders"><customerId>1</customerId>`+
+			`<item><sku>ABC-0001</sku></item><item><sku>ABC-0002</sku></item>`+
+			`</CreateOrder></Body></Envelope>`), body)
+		if err != nil || len(diffs) > 0 {
+			t.Errorf("unexpected request: %v %v", err, diffs)
+		}
+
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<CreateOrderResponse xmlns="http://example.com/orders"><orderId>A1</orderId><total>3</total></CreateOrderResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := c.CallJSON("CreateOrder", []byte(`{"customerId": "1", "item": [{"sku": "ABC-0001"}, {"sku": "ABC-0002"}]}`))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if string(b) != `{"CreateOrderResponse":{"orderId":"A1","total":3}}` {
+		t.Errorf("unexpected response: %s", b)
+	}
+}
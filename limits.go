@@ -0,0 +1,122 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/net/html/charset"
+)
+
+// DecodeLimits bounds the wsdl and the responses read by the Client, zero means no limit
+type DecodeLimits struct {
+	// MaxResponseSize in bytes of the wsdl and of the response bodies
+	MaxResponseSize int64
+	// MaxDepth of nested elements
+	MaxDepth int
+	// MaxAttributes of a single element, namespace declarations included
+	MaxAttributes int
+	// MaxTokenLength in bytes of text, attribute values, comments and names
+	MaxTokenLength int
+}
+
+// LimitError is returned when a wsdl or a response exceeds one of the DecodeLimits
+type LimitError struct {
+	Limit string
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d exceeded", e.Limit, e.Max)
+}
+
+// WithLimits bounds the wsdl and the responses read by the Client
+func WithLimits(l DecodeLimits) Option {
+	return func(c *Client) error {
+		c.Limits = &l
+		return nil
+	}
+}
+
+// readAll reads r up to MaxResponseSize
+func (l *DecodeLimits) readAll(r io.Reader) ([]byte, error) {
+	if l == nil || l.MaxResponseSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, l.MaxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(b)) > l.MaxResponseSize {
+		return nil, &LimitError{Limit: "MaxResponseSize", Max: l.MaxResponseSize}
+	}
+
+	return b, nil
+}
+
+// check walks the raw tokens of the xml document b enforcing the limits, before it's
+// decoded as usual. A token decoder can't be used as innerxml fields would be left empty
+func (l *DecodeLimits) check(b []byte) error {
+	if l == nil || (l.MaxDepth <= 0 && l.MaxAttributes <= 0 && l.MaxTokenLength <= 0) {
+		return nil
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.CharsetReader = charset.NewReaderLabel
+
+	depth := 0
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return &LimitError{Limit: "MaxDepth", Max: int64(l.MaxDepth)}
+			}
+
+			if l.MaxAttributes > 0 && len(t.Attr) > l.MaxAttributes {
+				return &LimitError{Limit: "MaxAttributes", Max: int64(l.MaxAttributes)}
+			}
+
+			err = l.checkLength(len(t.Name.Space) + len(t.Name.Local))
+			for _, a := range t.Attr {
+				if err == nil {
+					err = l.checkLength(len(a.Value))
+				}
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			err = l.checkLength(len(t))
+		case xml.Comment:
+			err = l.checkLength(len(t))
+		case xml.ProcInst:
+			err = l.checkLength(len(t.Inst))
+		case xml.Directive:
+			err = l.checkLength(len(t))
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (l *DecodeLimits) checkLength(n int) error {
+	if l.MaxTokenLength > 0 && n > l.MaxTokenLength {
+		return &LimitError{Limit: "MaxTokenLength", Max: int64(l.MaxTokenLength)}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package gosoap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const getOrderResponse = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><GetOrderResponse xmlns="http://example.com/orders"><orderId>%s</orderId><status>NEW</status>%s</GetOrderResponse></soap:Body></soap:Envelope>`
+
+func TestDecodeLimits(t *testing.T) {
+	limits := DecodeLimits{MaxResponseSize: 1 << 16, MaxDepth: 16, MaxAttributes: 8, MaxTokenLength: 256}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name: "within limits",
+			body: fmt.Sprintf(getOrderResponse, "1", ""),
+		},
+		{
+			name:    "response size",
+			body:    fmt.Sprintf(getOrderResponse, "1", strings.Repeat("<note>x</note>", 5000)),
+			wantErr: "MaxResponseSize",
+		},
+		{
+			name:    "depth",
+			body:    fmt.Sprintf(getOrderResponse, "1", strings.Repeat("<a>", 20)+strings.Repeat("</a>", 20)),
+			wantErr: "MaxDepth",
+		},
+		{
+			name:    "attributes",
+			body:    fmt.Sprintf(getOrderResponse, "1", `<a a="1" b="2" c="3" d="4" e="5" f="6" g="7" h="8" i="9"/>`),
+			wantErr: "MaxAttributes",
+		},
+		{
+			name:    "text length",
+			body:    fmt.Sprintf(getOrderResponse, strings.Repeat("1", 257), ""),
+			wantErr: "MaxTokenLength",
+		},
+		{
+			name:    "attribute length",
+			body:    fmt.Sprintf(getOrderResponse, "1", `<a b="`+strings.Repeat("x", 257)+`"/>`),
+			wantErr: "MaxTokenLength",
+		},
+		{
+			name:    "name length",
+			body:    fmt.Sprintf(getOrderResponse, "1", "<"+strings.Repeat("a", 257)+"/>"),
+			wantErr: "MaxTokenLength",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			})
+			defer ts.Close()
+
+			c, err := NewClient(ts.URL+"?wsdl", WithLimits(limits))
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+
+			res, err := c.Call("GetOrder", Params{"orderId": "1"})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("error not expected: %s", err)
+				}
+				if res.Body == nil {
+					t.Errorf("body expected")
+				}
+				return
+			}
+
+			var le *LimitError
+			if !errors.As(err, &le) || le.Limit != tt.wantErr {
+				t.Errorf("%s LimitError expected, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDecodeLimits_Wsdl(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", nil)
+	defer ts.Close()
+
+	for _, l := range []DecodeLimits{{MaxResponseSize: 512}, {MaxDepth: 3}, {MaxAttributes: 1}, {MaxTokenLength: 8}} {
+		c, err := NewClient(ts.URL+"?wsdl", WithLimits(l))
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+
+		var le *LimitError
+		if err := c.LoadDefinitions(); !errors.As(err, &le) {
+			t.Errorf("LimitError expected for %+v, got %v", l, err)
+		}
+	}
+
+	c, err := NewClient(ts.URL+"?wsdl", WithLimits(DecodeLimits{MaxResponseSize: 1 << 20, MaxDepth: 32, MaxAttributes: 16, MaxTokenLength: 1024}))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if err := c.LoadDefinitions(); err != nil {
+		t.Errorf("error not expected: %s", err)
+	}
+}
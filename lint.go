@@ -0,0 +1,564 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	wsdl11Namespace     = "http://schemas.xmlsoap.org/wsdl/"
+	wsdlSOAPNamespace   = "http://schemas.xmlsoap.org/wsdl/soap/"
+	wsdlSOAP12Namespace = "http://schemas.xmlsoap.org/wsdl/soap12/"
+	wsdlHTTPNamespace   = "http://schemas.xmlsoap.org/wsdl/http/"
+	xsdNamespace        = "http://www.w3.org/2001/XMLSchema"
+	soapHTTPTransport   = "http://schemas.xmlsoap.org/soap/http"
+)
+
+// unsupportedXSD describes the xml schema constructs ignored when building requests
+var unsupportedXSD = map[string]string{
+	"choice":         "xs:choice isn't supported, its elements are ignored",
+	"all":            "xs:all isn't supported, its elements are ignored",
+	"any":            "xs:any isn't supported",
+	"group":          "xs:group isn't supported, its elements are ignored",
+	"attribute":      "attributes aren't supported",
+	"attributeGroup": "attributes aren't supported",
+	"anyAttribute":   "attributes aren't supported",
+	"complexContent": "xs:complexContent isn't supported, derived types have no elements",
+	"simpleContent":  "xs:simpleContent isn't supported",
+	"list":           "xs:list isn't supported",
+	"union":          "xs:union isn't supported",
+	"include":        "xs:include isn't followed, its definitions are missing",
+	"redefine":       "xs:redefine isn't followed, its definitions are missing",
+}
+
+// LintWarning is a problem found by Lint in a WSDL document
+type LintWarning struct {
+	// Line of the element the warning is about
+	Line    int
+	Message string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+}
+
+// Lint checks the WSDL 1.1 or 2.0 document b for what keeps its operations from being
+// called: dangling references to messages, port types, bindings and schema types, ports
+// without soap:address, bindings other than SOAP 1.1, duplicate operation names, the
+// xml schema constructs the client ignores and the patterns the validation can't check.
+// The warnings are sorted by line
+func Lint(b []byte) ([]LintWarning, error) {
+	root, err := parseLintNode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{
+		tns:      root.attr("targetNamespace"),
+		defs:     map[string]map[xml.Name]bool{},
+		schemas:  map[string]bool{},
+		imported: map[string]bool{},
+	}
+
+	switch root.name {
+	case xml.Name{Space: wsdl11Namespace, Local: "definitions"}:
+		l.lintWSDL11(root)
+	case xml.Name{Space: wsdl20Namespace, Local: "description"}:
+		l.lintWSDL20(root)
+	default:
+		return nil, fmt.Errorf("%s is not a wsdl document", root.name.Local)
+	}
+
+	sort.SliceStable(l.warnings, func(i, j int) bool {
+		return l.warnings[i].Line < l.warnings[j].Line
+	})
+
+	return l.warnings, nil
+}
+
+// Lint reads the wsdl of the client and checks it with Lint
+func (c *Client) Lint() ([]LintWarning, error) {
+	reader, err := c.getWsdlBody()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	b, err := c.Limits.readAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Limits.check(b); err != nil {
+		return nil, err
+	}
+
+	return Lint(b)
+}
+
+type linter struct {
+	warnings []LintWarning
+	tns      string
+	// defs holds the names defined in the document by kind: message, portType, binding,
+	// interface, fault, element and type
+	defs map[string]map[xml.Name]bool
+	// schemas are the target namespaces of the inline schemas
+	schemas map[string]bool
+	// imported are the namespaces of the wsdl and schema imports, not checked
+	imported map[string]bool
+}
+
+func (l *linter) warn(n *lintNode, format string, a ...interface{}) {
+	l.warnings = append(l.warnings, LintWarning{Line: n.line, Message: fmt.Sprintf(format, a...)})
+}
+
+func (l *linter) define(kind string, name xml.Name) {
+	if l.defs[kind] == nil {
+		l.defs[kind] = map[xml.Name]bool{}
+	}
+
+	l.defs[kind][name] = true
+}
+
+// ref checks the qualified name v, found on n, references a kind defined in the document
+func (l *linter) ref(n *lintNode, v, kind string) {
+	if v == "" {
+		return
+	}
+
+	q, ok := n.qname(v)
+	if !ok {
+		l.warn(n, "%s references %s %q with undeclared prefix", n, kind, v)
+		return
+	}
+
+	switch kind {
+	case "element", "type":
+		if q.Space == xsdNamespace || (!l.schemas[q.Space] && l.imported[q.Space]) {
+			return
+		}
+	default:
+		if q.Space != l.tns && l.imported[q.Space] {
+			return
+		}
+	}
+
+	if !l.defs[kind][q] {
+		l.warn(n, "%s references undefined %s %q", n, kind, v)
+	}
+}
+
+// lintTypes collects the definitions of the inline schemas and checks their constructs
+func (l *linter) lintTypes(root *lintNode) {
+	for _, types := range root.children {
+		if types.name.Local != "types" {
+			continue
+		}
+
+		for _, s := range types.children {
+			if s.name != (xml.Name{Space: xsdNamespace, Local: "schema"}) {
+				continue
+			}
+
+			ns := s.attr("targetNamespace")
+			l.schemas[ns] = true
+			for _, c := range s.children {
+				switch {
+				case c.name.Space != xsdNamespace:
+				case c.name.Local == "element":
+					l.define("element", xml.Name{Space: ns, Local: c.attr("name")})
+				case c.name.Local == "complexType" || c.name.Local == "simpleType":
+					l.define("type", xml.Name{Space: ns, Local: c.attr("name")})
+				case c.name.Local == "import":
+					l.imported[c.attr("namespace")] = true
+				}
+			}
+		}
+	}
+
+	for _, types := range root.children {
+		if types.name.Local == "types" {
+			types.walk(l.lintSchemaNode)
+		}
+	}
+}
+
+func (l *linter) lintSchemaNode(n *lintNode) {
+	if n.name.Space != xsdNamespace {
+		return
+	}
+
+	if msg, ok := unsupportedXSD[n.name.Local]; ok {
+		l.warn(n, "%s", msg)
+	}
+
+	switch n.name.Local {
+	case "element":
+		l.ref(n, n.attr("ref"), "element")
+		l.ref(n, n.attr("type"), "type")
+	case "restriction", "extension":
+		l.ref(n, n.attr("base"), "type")
+	case "pattern":
+		if _, err := compilePattern(n.attr("value")); err != nil {
+			l.warn(n, "pattern %q isn't checked by the validation: %s", n.attr("value"), err)
+		}
+	case "sequence":
+		if n.parent != nil && n.parent.name.Space == xsdNamespace && n.parent.name.Local == "sequence" {
+			l.warn(n, "nested xs:sequence isn't supported, its elements are ignored")
+		}
+	case "import":
+		if loc := n.attr("schemaLocation"); loc != "" {
+			l.warn(n, "schema %q at %q isn't loaded", n.attr("namespace"), loc)
+		}
+	}
+}
+
+func (l *linter) lintWSDL11(root *lintNode) {
+	for _, c := range root.children {
+		if c.name.Space != wsdl11Namespace {
+			continue
+		}
+
+		switch c.name.Local {
+		case "import":
+			l.imported[c.attr("namespace")] = true
+			l.warn(c, "wsdl:import of %q isn't followed, its definitions are missing", c.attr("namespace"))
+		case "message", "portType", "binding":
+			l.define(c.name.Local, xml.Name{Space: l.tns, Local: c.attr("name")})
+		}
+	}
+
+	l.lintTypes(root)
+
+	portTypeOps := map[string]map[string]bool{}
+	services := 0
+	for _, c := range root.children {
+		if c.name.Space != wsdl11Namespace {
+			continue
+		}
+
+		switch c.name.Local {
+		case "message":
+			for _, p := range c.all(wsdl11Namespace, "part") {
+				l.ref(p, p.attr("element"), "element")
+				l.ref(p, p.attr("type"), "type")
+			}
+		case "portType":
+			ops := l.operations(c, wsdl11Namespace, "name")
+			portTypeOps[c.attr("name")] = ops
+			for _, o := range c.all(wsdl11Namespace, "operation") {
+				for _, m := range o.children {
+					if m.name.Space == wsdl11Namespace {
+						l.ref(m, m.attr("message"), "message")
+					}
+				}
+			}
+		case "service":
+			services++
+			for _, p := range c.all(wsdl11Namespace, "port") {
+				l.ref(p, p.attr("binding"), "binding")
+				l.lintAddress(p)
+			}
+		}
+	}
+
+	for _, c := range root.all(wsdl11Namespace, "binding") {
+		l.ref(c, c.attr("type"), "portType")
+		l.lintBinding11(c, portTypeOps[localName(c.attr("type"))])
+	}
+
+	if services == 0 {
+		l.warn(root, "no service defined")
+	}
+}
+
+// operations warns about the duplicate names of the operations of n and returns them
+func (l *linter) operations(n *lintNode, space, attr string) map[string]bool {
+	ops := map[string]bool{}
+	for _, o := range n.all(space, "operation") {
+		name := localName(o.attr(attr))
+		if ops[name] {
+			l.warn(o, "duplicate operation %q in %s", name, n)
+		}
+		ops[name] = true
+	}
+
+	return ops
+}
+
+func (l *linter) lintBinding11(b *lintNode, ops map[string]bool) {
+	switch {
+	case len(b.all(wsdlSOAPNamespace, "binding")) > 0:
+		if t := b.all(wsdlSOAPNamespace, "binding")[0].attr("transport"); t != soapHTTPTransport {
+			l.warn(b, "%s uses transport %q, only http is supported", b, t)
+		}
+	case len(b.all(wsdlSOAP12Namespace, "binding")) > 0:
+		l.warn(b, "%s is a SOAP 1.2 binding, only SOAP 1.1 is supported", b)
+	case len(b.all(wsdlHTTPNamespace, "binding")) > 0:
+		l.warn(b, "%s is an HTTP binding, only SOAP bindings are supported", b)
+	default:
+		l.warn(b, "%s has no soap:binding", b)
+	}
+
+	l.operations(b, wsdl11Namespace, "name")
+	for _, o := range b.all(wsdl11Namespace, "operation") {
+		if ops != nil && !ops[o.attr("name")] {
+			l.warn(o, "%s isn't defined by port type %q", o, b.attr("type"))
+		}
+
+		o.walk(func(n *lintNode) {
+			if n.name.Space == wsdlSOAPNamespace && n.attr("use") == "encoded" {
+				l.warn(n, "%s of %s uses encoded style, only literal is supported", n, o)
+			}
+		})
+	}
+}
+
+func (l *linter) lintAddress(p *lintNode) {
+	if a := p.all(wsdlSOAPNamespace, "address"); len(a) > 0 {
+		if a[0].attr("location") == "" {
+			l.warn(a[0], "soap:address of %s has no location", p)
+		}
+		return
+	}
+
+	// the binding is reported as unsupported
+	if len(p.all(wsdlSOAP12Namespace, "address")) > 0 || len(p.all(wsdlHTTPNamespace, "address")) > 0 {
+		return
+	}
+
+	l.warn(p, "%s has no soap:address", p)
+}
+
+func (l *linter) lintWSDL20(root *lintNode) {
+	for _, c := range root.children {
+		if c.name.Space != wsdl20Namespace {
+			continue
+		}
+
+		switch c.name.Local {
+		case "import":
+			l.imported[c.attr("namespace")] = true
+			l.warn(c, "wsdl:import of %q isn't followed, its definitions are missing", c.attr("namespace"))
+		case "include":
+			l.warn(c, "wsdl:include of %q isn't followed, its definitions are missing", c.attr("location"))
+		case "interface", "binding":
+			l.define(c.name.Local, xml.Name{Space: l.tns, Local: c.attr("name")})
+			for _, f := range c.all(wsdl20Namespace, "fault") {
+				l.define("fault", xml.Name{Space: l.tns, Local: f.attr("name")})
+			}
+		}
+	}
+
+	l.lintTypes(root)
+
+	interfaceOps := map[string]bool{}
+	services := 0
+	for _, c := range root.children {
+		if c.name.Space != wsdl20Namespace {
+			continue
+		}
+
+		switch c.name.Local {
+		case "interface":
+			for _, e := range strings.Fields(c.attr("extends")) {
+				l.ref(c, e, "interface")
+			}
+			for _, f := range c.all(wsdl20Namespace, "fault") {
+				l.ref(f, f.attr("element"), "element")
+			}
+
+			for name := range l.operations(c, wsdl20Namespace, "name") {
+				interfaceOps[name] = true
+			}
+			for _, o := range c.all(wsdl20Namespace, "operation") {
+				for _, m := range o.children {
+					switch m.name.Local {
+					case "input", "output":
+						if !strings.HasPrefix(m.attr("element"), "#") {
+							l.ref(m, m.attr("element"), "element")
+						}
+					case "infault", "outfault":
+						l.ref(m, m.attr("ref"), "fault")
+					}
+				}
+			}
+		case "service":
+			services++
+			l.ref(c, c.attr("interface"), "interface")
+			for _, e := range c.all(wsdl20Namespace, "endpoint") {
+				l.ref(e, e.attr("binding"), "binding")
+				if e.attr("address") == "" {
+					l.warn(e, "%s has no address", e)
+				}
+			}
+		}
+	}
+
+	for _, b := range root.all(wsdl20Namespace, "binding") {
+		l.ref(b, b.attr("interface"), "interface")
+
+		if b.attr("type") != wsdl20SOAPBinding {
+			l.warn(b, "%s has type %q, only SOAP bindings are supported", b, b.attr("type"))
+		}
+
+		l.operations(b, wsdl20Namespace, "ref")
+		for _, o := range b.all(wsdl20Namespace, "operation") {
+			if !interfaceOps[localName(o.attr("ref"))] {
+				l.warn(o, "%s references undefined operation %q", o, o.attr("ref"))
+			}
+		}
+	}
+
+	if services == 0 {
+		l.warn(root, "no service defined")
+	}
+}
+
+// lintNode is an element of the linted document
+type lintNode struct {
+	name xml.Name
+	// attrs by local name, or by {namespace}local for qualified attributes
+	attrs map[string]string
+	// scope maps the prefixes in scope to their namespace
+	scope    map[string]string
+	line     int
+	parent   *lintNode
+	children []*lintNode
+}
+
+func (n *lintNode) String() string {
+	if name := n.attr("name"); name != "" {
+		return fmt.Sprintf("%s %q", n.name.Local, name)
+	}
+
+	return n.name.Local
+}
+
+func (n *lintNode) attr(name string) string {
+	return n.attrs[name]
+}
+
+// qname resolves the prefix of the qualified name v, unprefixed names are in the default namespace
+func (n *lintNode) qname(v string) (xml.Name, bool) {
+	prefix, local := "", v
+	if i := strings.Index(v, ":"); i >= 0 {
+		prefix, local = v[:i], v[i+1:]
+	}
+
+	ns, ok := n.scope[prefix]
+	if !ok && prefix != "" {
+		return xml.Name{}, false
+	}
+
+	return xml.Name{Space: ns, Local: local}, true
+}
+
+// all returns the children of n named space local
+func (n *lintNode) all(space, local string) []*lintNode {
+	var nodes []*lintNode
+	for _, c := range n.children {
+		if c.name.Space == space && c.name.Local == local {
+			nodes = append(nodes, c)
+		}
+	}
+
+	return nodes
+}
+
+// walk calls fn for n and its descendants
+func (n *lintNode) walk(fn func(*lintNode)) {
+	fn(n)
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+}
+
+// parseLintNode parses the document b keeping the line of each element
+func parseLintNode(b []byte) (*lintNode, error) {
+	var (
+		r         = bytes.NewReader(b)
+		text      = b
+		converted bytes.Buffer
+	)
+
+	d := xml.NewDecoder(r)
+	// offsets following the xml declaration are counted in the converted text
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		cr, err := charset.NewReaderLabel(label, input)
+		if err != nil {
+			return nil, err
+		}
+
+		converted.Write(b[:len(b)-r.Len()])
+		return io.TeeReader(cr, &converted), nil
+	}
+
+	var (
+		stack      []*lintNode
+		line, read = 1, 0
+	)
+	for {
+		offset := int(d.InputOffset())
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no document element")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s, ok := t.(xml.StartElement)
+		if !ok {
+			if _, ok := t.(xml.EndElement); ok {
+				n := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if len(stack) == 0 {
+					return n, nil
+				}
+			}
+			continue
+		}
+
+		if converted.Len() > 0 {
+			text = converted.Bytes()
+		}
+		line += bytes.Count(text[read:offset], []byte("\n"))
+		read = offset
+
+		n := &lintNode{name: s.Name, attrs: map[string]string{}, scope: map[string]string{}, line: line}
+		if len(stack) > 0 {
+			n.parent = stack[len(stack)-1]
+			n.scope = n.parent.scope
+		}
+
+		copied := false
+		for _, a := range s.Attr {
+			if p, ok := namespaceDecl(a.Name); ok {
+				if !copied {
+					n.scope = copyScope(n.scope)
+					copied = true
+				}
+				n.scope[p] = a.Value
+				continue
+			}
+
+			if a.Name.Space == "" {
+				n.attrs[a.Name.Local] = a.Value
+			} else {
+				n.attrs["{"+a.Name.Space+"}"+a.Name.Local] = a.Value
+			}
+		}
+
+		if n.parent != nil {
+			n.parent.children = append(n.parent.children, n)
+		}
+		stack = append(stack, n)
+	}
+}
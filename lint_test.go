@@ -0,0 +1,137 @@
+package gosoap
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		wsdl string
+		want []string
+	}{
+		{wsdl: "orders.wsdl"},
+		{
+			wsdl: "broken.wsdl",
+			want: []string{
+				`line 8: element "at" references undefined type "tns:Timestamp"`,
+				`line 9: xs:choice isn't supported, its elements are ignored`,
+				`line 14: attributes aren't supported`,
+				`line 23: part "parameters" references undefined element "tns:Pong"`,
+				`line 28: output references undefined message "tns:PingOut"`,
+				`line 30: duplicate operation "Ping" in portType "PingPortType"`,
+				`line 39: body of operation "Ping" uses encoded style, only literal is supported`,
+				`line 42: operation "Pong" isn't defined by port type "tns:PingPortType"`,
+				`line 46: binding "PingSoap12Binding" is a SOAP 1.2 binding, only SOAP 1.1 is supported`,
+				`line 50: port "PingPort" has no soap:address`,
+				`line 54: port "LostPort" references undefined binding "tns:LostBinding"`,
+			},
+		},
+		{
+			wsdl: "orders20.wsdl",
+			want: []string{
+				`line 107: binding "OrderHTTPBinding" has type "http://www.w3.org/ns/wsdl/http", only SOAP bindings are supported`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wsdl, func(t *testing.T) {
+			b, err := ioutil.ReadFile("testdata/" + tt.wsdl)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			warnings, err := Lint(b)
+			if err != nil {
+				t.Fatalf("error not expected: %s", err)
+			}
+
+			var got []string
+			for _, w := range warnings {
+				got = append(got, w.String())
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("unexpected warnings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLint_WSDL20(t *testing.T) {
+	b := []byte(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<!-- ` + strings.Repeat("\xe7", 100) + ` -->
+<description xmlns="http://www.w3.org/ns/wsdl" xmlns:tns="urn:t" xmlns:wsoap="http://www.w3.org/ns/wsdl/soap" targetNamespace="urn:t">
+  <interface name="I" extends="tns:Missing">
+    <operation name="Op"><input element="#any"/><outfault ref="tns:F"/></operation>
+    <operation name="Op"/>
+  </interface>
+  <binding name="B" interface="tns:I" type="http://www.w3.org/ns/wsdl/soap" wsoap:version="1.1">
+    <operation ref="tns:Other"/>
+  </binding>
+  <service name="S" interface="tns:I">
+    <endpoint name="E" binding="tns:B"/>
+  </service>
+</description>`)
+
+	// each ç is two bytes once converted to UTF-8, the lines must not drift
+	warnings, err := Lint(b)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := []string{
+		`line 4: interface "I" references undefined interface "tns:Missing"`,
+		`line 5: outfault references undefined fault "tns:F"`,
+		`line 6: duplicate operation "Op" in interface "I"`,
+		`line 9: operation references undefined operation "tns:Other"`,
+		`line 12: endpoint "E" has no address`,
+	}
+
+	var got []string
+	for _, w := range warnings {
+		got = append(got, w.String())
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected warnings:\n%s", strings.Join(got, "\n"))
+	}
+
+	if _, err := Lint([]byte(`<Envelope/>`)); err == nil {
+		t.Error("error expected for a document other than wsdl")
+	}
+}
+
+func TestLint_Patterns(t *testing.T) {
+	w, err := Lint([]byte(`<wsdl:definitions xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/" targetNamespace="urn:p">
+  <wsdl:types>
+    <xs:schema targetNamespace="urn:p">
+      <xs:simpleType name="Consonants">
+        <xs:restriction base="xs:string">
+          <xs:pattern value="[a-z-[aeiou]]+" />
+          <xs:pattern value="\i\c*" />
+        </xs:restriction>
+      </xs:simpleType>
+    </xs:schema>
+  </wsdl:types>
+</wsdl:definitions>`))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := `line 6: pattern "[a-z-[aeiou]]+" isn't checked by the validation: character class subtraction isn't supported`
+	found := false
+	for _, l := range w {
+		if strings.Contains(l.String(), "pattern") {
+			if l.String() != want || found {
+				t.Errorf("unexpected warning %s", l)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("warning %s expected, got %v", want, w)
+	}
+}
@@ -0,0 +1,82 @@
+package gosoap
+
+import (
+	"encoding/xml"
+	"sort"
+)
+
+// EnvelopeNamespaces controls the namespace prefixes and declarations of the request envelope
+type EnvelopeNamespaces struct {
+	// SoapPrefix of the Envelope, Header and Body elements, soap if empty
+	SoapPrefix string
+	// XsiPrefix and XsdPrefix of the schema instance and schema namespaces, xsi and xsd if empty
+	XsiPrefix string
+	XsdPrefix string
+	// Envelope, Header and Body hold extra namespace declarations of those elements, by prefix
+	Envelope map[string]string
+	Header   map[string]string
+	Body     map[string]string
+	// OperationPrefix declares the operation namespace with this prefix on the Envelope and
+	// prefixes the operation element instead of making it the default namespace. Params
+	// elements are prefixed too when the schema elementFormDefault is qualified
+	OperationPrefix string
+}
+
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// soapNamespace returns the envelope namespace of the SOAP version, 1.1 or 1.2
+func soapNamespace(version string) string {
+	if version == "1.2" {
+		return soap12Namespace
+	}
+
+	return soap11Namespace
+}
+
+// DefaultEnvelopeNamespaces is used when the Client doesn't set Namespaces
+var DefaultEnvelopeNamespaces = EnvelopeNamespaces{SoapPrefix: "soap", XsiPrefix: "xsi", XsdPrefix: "xsd"}
+
+// withDefaults returns a copy of ns with the default prefixes set
+func (ns EnvelopeNamespaces) withDefaults() EnvelopeNamespaces {
+	if ns.SoapPrefix == "" {
+		ns.SoapPrefix = "soap"
+	}
+
+	if ns.XsiPrefix == "" {
+		ns.XsiPrefix = "xsi"
+	}
+
+	if ns.XsdPrefix == "" {
+		ns.XsdPrefix = "xsd"
+	}
+
+	return ns
+}
+
+// soapName returns the name of the envelope element n with the soap prefix
+func (ns EnvelopeNamespaces) soapName(n string) xml.Name {
+	return xml.Name{Space: "", Local: ns.SoapPrefix + ":" + n}
+}
+
+// namespaceAttrs returns the declarations of decls, ordered by prefix
+func namespaceAttrs(decls map[string]string) []xml.Attr {
+	prefixes := make([]string, 0, len(decls))
+	for p := range decls {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	attrs := make([]xml.Attr, 0, len(prefixes))
+	for _, p := range prefixes {
+		name := "xmlns"
+		if p != "" {
+			name += ":" + p
+		}
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Space: "", Local: name}, Value: decls[p]})
+	}
+
+	return attrs
+}
@@ -0,0 +1,51 @@
+package gosoap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClient_Namespaces(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+	p := &process{
+		Client: &Client{
+			Definitions:  d,
+			HeaderParams: HeaderParams{"token": "t"},
+			Namespaces: &EnvelopeNamespaces{
+				SoapPrefix:      "soapenv",
+				Envelope:        map[string]string{"wsa": "http://www.w3.org/2005/08/addressing", "soapenv": "ignored"},
+				Header:          map[string]string{"sec": "urn:security"},
+				Body:            map[string]string{"b": "urn:body"},
+				OperationPrefix: "ord",
+			},
+		},
+		Request: NewArrayRequest("GetOrder", ArrayParams{{"orderId", "7"}, {"b:extra", "x"}}),
+	}
+
+	b, err := PayloadFormat{}.marshal(p)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := `<soapenv:Envelope xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ord="http://example.com/orders" xmlns:wsa="http://www.w3.org/2005/08/addressing">` +
+		`<soapenv:Header xmlns:sec="urn:security"><token>t</token></soapenv:Header>` +
+		`<soapenv:Body xmlns:b="urn:body"><ord:GetOrder><ord:orderId>7</ord:orderId><b:extra>x</b:extra></ord:GetOrder></soapenv:Body>` +
+		`</soapenv:Envelope>`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	diffs, err := DiffXML(b, []byte(`<Envelope xmlns="http://schemas.xmlsoap.org/soap/envelope/">`+
+		`<Header><token xmlns="">t</token></Header>`+
+		`<Body><GetOrder xmlns="http://example.com/orders"><orderId>7</orderId><extra xmlns="urn:body">x</extra></GetOrder></Body>`+
+		`</Envelope>`))
+	if err != nil || len(diffs) > 0 {
+		t.Errorf("envelope must be equivalent to the default one: %v %v", err, diffs)
+	}
+
+	p.Client.Namespaces = nil
+	b, _ = PayloadFormat{}.marshal(p)
+	if !strings.HasPrefix(string(b), `<soap:Envelope xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Header>`) {
+		t.Errorf("unexpected default envelope:\n%s", b)
+	}
+}
@@ -0,0 +1,130 @@
+package gosoap
+
+import (
+	"encoding/json"
+)
+
+// OpenAPI returns the OpenAPI 3 document of the operations as exposed by Gateway
+func (wsdl *wsdlDefinitions) OpenAPI() ([]byte, error) {
+	title := wsdl.Name
+	if title == "" && len(wsdl.Services) > 0 {
+		title = wsdl.Services[0].Name
+	}
+
+	errorSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"code":    map[string]interface{}{"type": "string"},
+					"message": map[string]interface{}{"type": "string"},
+					"detail":  map[string]interface{}{},
+				},
+			},
+		},
+	}
+
+	paths := map[string]interface{}{}
+	for _, name := range wsdl.OperationNames() {
+		in, err := wsdl.InputSchema(name)
+		if err != nil {
+			return nil, err
+		}
+
+		responses := map[string]interface{}{
+			"default": jsonContent("Error", errorSchema),
+		}
+
+		if out, err := wsdl.OutputSchema(name); err == nil {
+			responses["200"] = jsonContent("Success", map[string]interface{}{
+				"type":     "object",
+				"required": []string{out.Name},
+				"properties": map[string]interface{}{
+					out.Name: jsonSchema(out),
+				},
+			})
+		}
+
+		paths["/"+name] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": name,
+				"requestBody": map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": jsonSchema(in)},
+					},
+				},
+				"responses": responses,
+			},
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": "1.0",
+		},
+		"paths": paths,
+	})
+}
+
+func jsonContent(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// jsonSchema returns the JSON schema of the values of s, not taking its repetition into account
+func jsonSchema(s *SchemaElement) map[string]interface{} {
+	if len(s.Children) == 0 {
+		switch xsdKind(s.Type) {
+		case kindInteger:
+			return map[string]interface{}{"type": "integer"}
+		case kindDecimal:
+			return map[string]interface{}{"type": "number"}
+		case kindBoolean:
+			return map[string]interface{}{"type": "boolean"}
+		}
+
+		return map[string]interface{}{"type": "string"}
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, c := range s.Children {
+		p := jsonSchema(c)
+		if c.IsRepeated() {
+			p = map[string]interface{}{"type": "array", "items": p}
+			if c.MinOccurs > 0 {
+				p["minItems"] = c.MinOccurs
+			}
+			if c.MaxOccurs != Unbounded {
+				p["maxItems"] = c.MaxOccurs
+			}
+		}
+
+		if c.Nillable {
+			p["nullable"] = true
+		}
+
+		properties[c.Name] = p
+		if !c.IsOptional() {
+			required = append(required, c.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
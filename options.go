@@ -0,0 +1,171 @@
+package gosoap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a Client created with NewClient
+type Option func(*Client) error
+
+// NewClient return new *Client to handle the requests with the WSDL, configured by opts.
+// Unlike SoapClient, the http client has its own transport, with the defaults of
+// http.DefaultTransport, so transport options don't affect other clients
+func NewClient(wsdl string, opts ...Option) (*Client, error) {
+	c, err := SoapClient(wsdl)
+	if err != nil {
+		return nil, err
+	}
+
+	c.dialer = &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		c.roundTripper = t.Clone()
+	} else {
+		// http.DefaultTransport was replaced, use the defaults it's declared with
+		c.roundTripper = &http.Transport{
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	}
+	c.roundTripper.DialContext = c.dialer.DialContext
+	c.roundTripper.Proxy = func(r *http.Request) (*url.URL, error) {
+		if c.proxy != nil {
+			return c.proxy, nil
+		}
+		return http.ProxyFromEnvironment(r)
+	}
+	c.HttpClient = &http.Client{Transport: c.roundTripper}
+
+	for _, o := range opts {
+		if err := o(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// WithTimeout limits the time of each http request, response body reading included
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.HttpClient.Timeout = d
+		return nil
+	}
+}
+
+// WithConnectTimeout limits the time to establish a connection
+func WithConnectTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.dialer.Timeout = d
+		return nil
+	}
+}
+
+// WithTLSHandshakeTimeout limits the time of the TLS handshake
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.roundTripper.TLSHandshakeTimeout = d
+		return nil
+	}
+}
+
+// WithResponseHeaderTimeout limits the time waiting for the response headers once the request is sent
+func WithResponseHeaderTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		c.roundTripper.ResponseHeaderTimeout = d
+		return nil
+	}
+}
+
+// WithProxy sends the requests through the proxy at rawURL instead of the one of the
+// environment. Credentials in the URL are used for proxy authentication
+func WithProxy(rawURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy url %q", rawURL)
+		}
+
+		if c.proxy != nil && c.proxy.User != nil && u.User == nil {
+			u.User = c.proxy.User
+		}
+
+		c.proxy = u
+		return nil
+	}
+}
+
+// WithProxyAuth authenticates on the proxy set by WithProxy with basic auth
+func WithProxyAuth(username, password string) Option {
+	return func(c *Client) error {
+		if c.proxy == nil {
+			return fmt.Errorf("proxy auth requires WithProxy before it")
+		}
+
+		c.proxy.User = url.UserPassword(username, password)
+		return nil
+	}
+}
+
+// WithKeepAlive sets the period of the TCP keep-alive probes, a negative
+// period disables them as well as the reuse of connections between requests
+func WithKeepAlive(period time.Duration) Option {
+	return func(c *Client) error {
+		c.dialer.KeepAlive = period
+		c.roundTripper.DisableKeepAlives = period < 0
+		return nil
+	}
+}
+
+// WithIdleConns limits the idle connections kept for reuse per host and the time they're kept
+func WithIdleConns(maxPerHost int, timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.roundTripper.MaxIdleConnsPerHost = maxPerHost
+		c.roundTripper.IdleConnTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxConnsPerHost limits the connections per host, in use or idle. Zero means no limit
+func WithMaxConnsPerHost(n int) Option {
+	return func(c *Client) error {
+		c.roundTripper.MaxConnsPerHost = n
+		return nil
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 on TLS connections
+func WithHTTP2(enabled bool) Option {
+	return func(c *Client) error {
+		c.roundTripper.ForceAttemptHTTP2 = enabled
+		if !enabled {
+			c.roundTripper.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		} else {
+			c.roundTripper.TLSNextProto = nil
+		}
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests
+func WithUserAgent(ua string) Option {
+	return func(c *Client) error {
+		c.UserAgent = ua
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+package gosoap
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient("http://localhost/service?wsdl",
+		WithTimeout(time.Minute),
+		WithConnectTimeout(time.Second),
+		WithTLSHandshakeTimeout(2*time.Second),
+		WithResponseHeaderTimeout(3*time.Second),
+		WithKeepAlive(-1),
+		WithIdleConns(4, 5*time.Second),
+		WithMaxConnsPerHost(6),
+		WithHTTP2(false),
+		WithUserAgent("test/1.0"),
+	)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	tr := c.HttpClient.Transport.(*http.Transport)
+	switch {
+	case c.HttpClient.Timeout != time.Minute:
+		t.Errorf("unexpected timeout %s", c.HttpClient.Timeout)
+	case c.dialer.Timeout != time.Second:
+		t.Errorf("unexpected connect timeout %s", c.dialer.Timeout)
+	case tr.TLSHandshakeTimeout != 2*time.Second || tr.ResponseHeaderTimeout != 3*time.Second:
+		t.Errorf("unexpected transport timeouts %s %s", tr.TLSHandshakeTimeout, tr.ResponseHeaderTimeout)
+	case !tr.DisableKeepAlives || tr.MaxIdleConnsPerHost != 4 || tr.IdleConnTimeout != 5*time.Second || tr.MaxConnsPerHost != 6:
+		t.Errorf("unexpected connection settings %+v", tr)
+	case tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil:
+		t.Errorf("http2 must be disabled")
+	case c.UserAgent != "test/1.0":
+		t.Errorf("unexpected user agent %q", c.UserAgent)
+	case tr == http.DefaultTransport:
+		t.Errorf("default transport must not be shared")
+	}
+
+	for _, o := range []Option{WithProxy("://x"), WithProxy("localhost"), WithProxyAuth("u", "p")} {
+		if _, err := NewClient("http://localhost/service?wsdl", o); err == nil {
+			t.Errorf("error expected")
+		}
+	}
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestNewClient_ReplacedDefaultTransport(t *testing.T) {
+	defer func(t http.RoundTripper) { http.DefaultTransport = t }(http.DefaultTransport)
+	http.DefaultTransport = roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, fmt.Errorf("not used")
+	})
+
+	c, err := NewClient("http://localhost/service?wsdl", WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	tr := c.HttpClient.Transport.(*http.Transport)
+	if tr.IdleConnTimeout != 90*time.Second || tr.TLSHandshakeTimeout != 10*time.Second || tr.Proxy == nil || c.dialer.Timeout != time.Second {
+		t.Errorf("transport with the defaults expected, got %+v", tr)
+	}
+}
+
+func TestNewClient_Proxy(t *testing.T) {
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = true
+		if want := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret")); r.Header.Get("Proxy-Authorization") != want {
+			t.Errorf("unexpected proxy authorization %q", r.Header.Get("Proxy-Authorization"))
+		}
+
+		if r.Header.Get("User-Agent") != "test/1.0" {
+			t.Errorf("unexpected user agent %q", r.Header.Get("User-Agent"))
+		}
+
+		if r.URL.Host != "soap.invalid" {
+			t.Errorf("unexpected host %q", r.URL.Host)
+		}
+
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer proxy.Close()
+
+	c, err := NewClient("http://soap.invalid/service?wsdl", WithProxy(proxy.URL), WithProxyAuth("user", "secret"), WithUserAgent("test/1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.LoadDefinitions()
+	if !proxied {
+		t.Errorf("wsdl must be requested through the proxy")
+	}
+}
+
+func TestNewClient_Timeout(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`)
+	})
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL, WithResponseHeaderTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Call("GetOrder", Params{"orderId": "1"}); err == nil {
+		t.Errorf("timeout error expected")
+	}
+}
@@ -0,0 +1,81 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// RawXML is an xml fragment written as is in the envelope instead of being escaped.
+// It may be used as a param value or as the whole body of a request with NewRawRequest
+type RawXML string
+
+// NewRawRequest returns a request to the method m whose soap:Body holds the fragment body,
+// which must include the operation element itself
+func NewRawRequest(m string, body RawXML) *Request {
+	return &Request{
+		Method: m,
+		Body:   body,
+	}
+}
+
+// tokens checks the fragment is well-formed and returns its tokens, keeping the
+// namespace prefixes as written. Document type declarations are not allowed
+func (r RawXML) tokens() ([]xml.Token, error) {
+	d := xml.NewDecoder(bytes.NewReader([]byte(r)))
+	d.Strict = true
+
+	var (
+		tokens []xml.Token
+		open   []xml.Name
+	)
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("raw xml is not well-formed: %s", err)
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			open = append(open, t.Name)
+
+			s := xml.StartElement{Name: rawName(t.Name)}
+			for _, a := range t.Attr {
+				s.Attr = append(s.Attr, xml.Attr{Name: rawName(a.Name), Value: a.Value})
+			}
+			tokens = append(tokens, s)
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, fmt.Errorf("raw xml is not well-formed: unexpected end element %s", qualifiedName(t.Name))
+			}
+			open = open[:len(open)-1]
+
+			tokens = append(tokens, xml.EndElement{Name: rawName(t.Name)})
+		case xml.CharData:
+			tokens = append(tokens, t.Copy())
+		case xml.Comment:
+			tokens = append(tokens, t.Copy())
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				tokens = append(tokens, t.Copy())
+			}
+		case xml.Directive:
+			return nil, fmt.Errorf("raw xml must not hold directives")
+		}
+	}
+
+	if len(open) > 0 {
+		return nil, fmt.Errorf("raw xml is not well-formed: element %s not closed", qualifiedName(open[len(open)-1]))
+	}
+
+	return tokens, nil
+}
+
+// rawName keeps the prefix of n in the local name, so the encoder writes it as is
+func rawName(n xml.Name) xml.Name {
+	return xml.Name{Space: "", Local: qualifiedName(n)}
+}
@@ -0,0 +1,65 @@
+package gosoap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRawXML(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+	p := &process{
+		Client:  &Client{Definitions: d},
+		Request: NewArrayRequest("CreateOrder", ArrayParams{{"customerId", "1"}, {"address", RawXML(`<street a="1 &amp; 2">Main &amp; 1st</street><o:city xmlns:o="http://example.com/orders">Lisbon</o:city>`)}}),
+	}
+
+	b, err := PayloadFormat{}.marshal(p)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := `<CreateOrder xmlns="http://example.com/orders"><customerId>1</customerId>` +
+		`<address><street a="1 &amp; 2">Main &amp; 1st</street><o:city xmlns:o="http://example.com/orders">Lisbon</o:city></address></CreateOrder>`
+	if !strings.Contains(string(b), want) {
+		t.Errorf("payload must contain %s, got:\n%s", want, b)
+	}
+
+	p.Request = NewRawRequest("GetOrder", `<?xml version="1.0"?><ord:GetOrder xmlns:ord="http://example.com/orders"><!-- raw --><ord:orderId>7</ord:orderId></ord:GetOrder>`)
+	b, err = PayloadFormat{}.marshal(p)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want = `<soap:Body><ord:GetOrder xmlns:ord="http://example.com/orders"><!-- raw --><ord:orderId>7</ord:orderId></ord:GetOrder></soap:Body></soap:Envelope>`
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("payload must end with %s, got:\n%s", want, b)
+	}
+
+	for _, raw := range []RawXML{
+		`<a><b></a>`,
+		`<a>`,
+		`</a>`,
+		`<a>&unknown;</a>`,
+		`<!DOCTYPE a [<!ENTITY x "y">]><a/>`,
+	} {
+		p.Request = NewRequest("GetOrder", Params{"orderId": raw})
+		if _, err := (PayloadFormat{}).marshal(p); err == nil {
+			t.Errorf("error expected for %s", raw)
+		}
+	}
+}
+
+func TestNewRequest_Params(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	req := NewRequest("GetOrder", Params{})
+	req.Params["orderId"] = "1"
+	req.ArrayParams = ArrayParams{{"note", "a"}, {"note", "b"}}
+
+	b, err := DefaultPayloadFormat.marshal(&process{Client: &Client{Definitions: d}, Request: req})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if !strings.Contains(string(b), "<orderId>1</orderId>") || !strings.Contains(string(b), "<note>a</note>") {
+		t.Errorf("unexpected envelope %s", b)
+	}
+}
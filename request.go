@@ -2,12 +2,25 @@ package gosoap
 
 import (
 	"fmt"
+	"net/http"
 )
 
 // Soap Request
 type Request struct {
 	Method string
 	Params Params
+	// ArrayParams are encoded in order, after Params
+	ArrayParams ArrayParams
+	// Body replaces the operation element and its params in soap:Body, see NewRawRequest
+	Body RawXML
+	// PayloadFormat overrides the one of the Client for this request
+	PayloadFormat *PayloadFormat
+	// HTTPHeader is added to the headers of the http request
+	HTTPHeader http.Header
+	// IdempotencyKey identifies the logical call in the Idempotency store, e.g. a payment reference
+	IdempotencyKey string
+	// MessageID sent with Client.Idempotency, set on the first Do and reused by the next ones
+	MessageID string
 }
 
 func NewRequest(m string, p Params) *Request {
@@ -17,6 +30,14 @@ func NewRequest(m string, p Params) *Request {
 	}
 }
 
+// NewArrayRequest returns a request to the method m with ArrayParams p
+func NewArrayRequest(m string, p ArrayParams) *Request {
+	return &Request{
+		Method:      m,
+		ArrayParams: p,
+	}
+}
+
 type RequestStruct interface {
 	SoapBuildRequest() *Request
 }
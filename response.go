@@ -1,15 +1,31 @@
 package gosoap
 
 import (
+	"crypto/x509"
 	"encoding/xml"
 	"fmt"
+	"reflect"
 )
 
 // Soap Response
 type Response struct {
-	Body    []byte
-	Header  []byte
+	Body   []byte
+	Header []byte
+	// Payload is the envelope of the request, see RequestEnvelope
 	Payload []byte
+	// Envelope is the envelope of the response as received
+	Envelope []byte
+	// Exchange is the http request and response, see DumpExchange
+	Exchange *Exchange
+	// Sizes of the request and response envelopes, before and after compression
+	Sizes TransferSizes
+	// SignerCertificate of the response verified by the Client SignatureVerifier
+	SignerCertificate *x509.Certificate
+
+	// registered fault detail types of the Client
+	faultDetails map[xml.Name]reflect.Type
+	// scope holds the namespace declarations in effect for the Body content
+	scope []xml.Attr
 }
 
 // Unmarshal get the body and unmarshal into the interface
@@ -18,11 +34,47 @@ func (r *Response) Unmarshal(v interface{}) error {
 		return fmt.Errorf("Body is empty")
 	}
 
+	if err := r.fault(); err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(r.Body, v)
+}
+
+// fault returns a *FaultError if the body holds a soap fault
+func (r *Response) fault() error {
 	var f Fault
-	xml.Unmarshal(r.Body, &f)
+	decodeFault(r.Body, r.scope, &f)
 	if f.Code != "" {
-		return fmt.Errorf("[%s]: %s", f.Code, f.Description)
+		e := &FaultError{Code: f.Code, Description: f.Description, Detail: f.Detail, DetailXML: f.DetailXML}
+		if d := decodeFaultDetail(r.faultDetails, f.entries); d != nil {
+			e.Detail = d
+		}
+		return e
 	}
 
-	return xml.Unmarshal(r.Body, v)
+	return nil
+}
+
+// FaultError is returned when the response body holds a soap fault
+type FaultError struct {
+	Code        string
+	Description string
+	// Detail is decoded into the type registered with Client.RegisterFaultDetail,
+	// otherwise it's the text of the detail element
+	Detail    interface{}
+	DetailXML []byte
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("[%s]: %s", e.Code, e.Description)
+}
+
+// Unwrap returns Detail if it's an error, so errors.As finds registered detail types
+func (e *FaultError) Unwrap() error {
+	if err, ok := e.Detail.(error); ok {
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,219 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	saml2Namespace  = "urn:oasis:names:tc:SAML:2.0:assertion"
+	saml11Namespace = "urn:oasis:names:tc:SAML:1.0:assertion"
+
+	samlV20TokenType = "http://docs.oasis-open.org/wss/oasis-wss-saml-token-profile-1.1#SAMLV2.0"
+	samlV11TokenType = "http://docs.oasis-open.org/wss/oasis-wss-saml-token-profile-1.1#SAMLV1.1"
+	samlIDValueType  = "http://docs.oasis-open.org/wss/oasis-wss-saml-token-profile-1.1#SAMLID"
+	samlV11ValueType = "http://docs.oasis-open.org/wss/oasis-wss-saml-token-profile-1.0#SAMLAssertionID"
+)
+
+// SAMLToken places a SAML assertion, obtained from an identity provider, in the
+// wsse:Security header of the requests. The assertion is copied byte for byte so
+// its signature stays valid
+type SAMLToken struct {
+	// Assertion is the saml:Assertion element, an xml declaration before it is dropped
+	Assertion []byte
+	// Refresh returns a new assertion when there's none or the current one expires,
+	// the assertion is used until it expires if nil
+	Refresh func() ([]byte, error)
+	// RefreshBefore is the time before the NotOnOrAfter condition of the assertion
+	// when it's refreshed, one minute if zero
+	RefreshBefore time.Duration
+	// TokenReference adds a wsse:SecurityTokenReference to the assertion after it
+	TokenReference bool
+
+	mu      sync.Mutex
+	current *samlAssertion
+}
+
+// samlAssertion is a parsed SAMLToken assertion
+type samlAssertion struct {
+	xml          []byte
+	id           string
+	v2           bool
+	notOnOrAfter time.Time
+}
+
+// WithSAMLToken places the assertion of t in the security header of the requests
+func WithSAMLToken(t *SAMLToken) Option {
+	return func(c *Client) error {
+		if len(t.Assertion) == 0 && t.Refresh == nil {
+			return fmt.Errorf("SAML assertion or refresh callback is required")
+		}
+
+		c.SAMLToken = t
+		return nil
+	}
+}
+
+// assertion returns the current assertion, refreshed if needed
+func (t *SAMLToken) assertion() (*samlAssertion, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.current == nil && len(t.Assertion) > 0 {
+		a, err := parseSAMLAssertion(t.Assertion)
+		if err != nil {
+			return nil, err
+		}
+		t.current = a
+	}
+
+	before := t.RefreshBefore
+	if before == 0 {
+		before = time.Minute
+	}
+
+	expired := t.current == nil || (!t.current.notOnOrAfter.IsZero() && !time.Now().Add(before).Before(t.current.notOnOrAfter))
+	if !expired {
+		return t.current, nil
+	}
+
+	if t.Refresh == nil {
+		switch {
+		case t.current == nil:
+			return nil, fmt.Errorf("SAML assertion is required")
+		case time.Now().Before(t.current.notOnOrAfter):
+			// without refresh, it's used until it expires
+			return t.current, nil
+		}
+		return nil, fmt.Errorf("SAML assertion expired")
+	}
+
+	b, err := t.Refresh()
+	if err != nil {
+		return nil, fmt.Errorf("SAML assertion refresh failed: %w", err)
+	}
+
+	a, err := parseSAMLAssertion(b)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Assertion, t.current = b, a
+	return a, nil
+}
+
+// SecurityTokenReference returns a wsse:SecurityTokenReference to the current assertion,
+// as defined by the WSS SAML Token Profile 1.1, e.g. for a ds:KeyInfo
+func (t *SAMLToken) SecurityTokenReference() ([]byte, error) {
+	a, err := t.assertion()
+	if err != nil {
+		return nil, err
+	}
+
+	return a.tokenReference(""), nil
+}
+
+func (a *samlAssertion) tokenReference(id string) []byte {
+	tokenType, valueType := samlV20TokenType, samlIDValueType
+	if !a.v2 {
+		tokenType, valueType = samlV11TokenType, samlV11ValueType
+	}
+
+	attrs := ""
+	if id != "" {
+		attrs = fmt.Sprintf(` xmlns:wsu="%s" wsu:Id="%s"`, wsuNamespace, id)
+	}
+
+	return []byte(fmt.Sprintf(`<wsse:SecurityTokenReference xmlns:wsse="%s" xmlns:wsse11="%s"%s wsse11:TokenType="%s">`+
+		`<wsse:KeyIdentifier ValueType="%s">%s</wsse:KeyIdentifier></wsse:SecurityTokenReference>`,
+		wsseNamespace, wsse11Namespace, attrs, tokenType, valueType, escapeXML(a.id)))
+}
+
+// securityEntries returns the assertion, and its reference if configured, for the security header
+func (t *SAMLToken) securityEntries() ([]byte, error) {
+	a, err := t.assertion()
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.TokenReference {
+		return a.xml, nil
+	}
+
+	id, err := newSecurityID("STR")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]byte(nil), a.xml...), a.tokenReference(id)...), nil
+}
+
+// addTo returns the envelope b with the assertion in its security header
+func (t *SAMLToken) addTo(b []byte) ([]byte, error) {
+	entries, err := t.securityEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := parseEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.withSecurity(entries), nil
+}
+
+// parseSAMLAssertion checks b holds a SAML 1.1 or 2.0 assertion and returns it
+func parseSAMLAssertion(b []byte) (*samlAssertion, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		before := int(d.InputOffset())
+		tok, err := d.Token()
+		if err != nil {
+			return nil, fmt.Errorf("SAML assertion not found: %s", err)
+		}
+
+		s, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if s.Name.Local != "Assertion" || (s.Name.Space != saml2Namespace && s.Name.Space != saml11Namespace) {
+			return nil, fmt.Errorf("SAML assertion expected, found %s", s.Name.Local)
+		}
+
+		var v struct {
+			ID          string `xml:"ID,attr"`
+			AssertionID string `xml:"AssertionID,attr"`
+			Conditions  struct {
+				NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+			} `xml:"Conditions"`
+		}
+		if err := d.DecodeElement(&v, &s); err != nil {
+			return nil, fmt.Errorf("invalid SAML assertion: %s", err)
+		}
+
+		a := &samlAssertion{
+			xml: b[before:d.InputOffset()],
+			id:  v.ID,
+			v2:  s.Name.Space == saml2Namespace,
+		}
+		if !a.v2 {
+			a.id = v.AssertionID
+		}
+		if a.id == "" {
+			return nil, fmt.Errorf("SAML assertion has no ID")
+		}
+
+		if v.Conditions.NotOnOrAfter != "" {
+			if a.notOnOrAfter, err = time.Parse(time.RFC3339, v.Conditions.NotOnOrAfter); err != nil {
+				return nil, fmt.Errorf("invalid SAML assertion NotOnOrAfter: %s", err)
+			}
+		}
+
+		return a, nil
+	}
+}
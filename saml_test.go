@@ -0,0 +1,124 @@
+package gosoap
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAssertion(id string, notOnOrAfter time.Time) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0"?>
+<saml2:Assertion xmlns:saml2="urn:oasis:names:tc:SAML:2.0:assertion" ID="%s" IssueInstant="2024-01-01T00:00:00Z" Version="2.0">
+  <saml2:Issuer>https://idp.example.com</saml2:Issuer>
+  <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:SignatureValue>c2lnbmVk
+PQ==</ds:SignatureValue></ds:Signature>
+  <saml2:Conditions NotBefore="2024-01-01T00:00:00Z"   NotOnOrAfter="%s"/>
+</saml2:Assertion>`, id, notOnOrAfter.UTC().Format(time.RFC3339)))
+}
+
+func TestSAMLToken(t *testing.T) {
+	var bodies [][]byte
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, body)
+		fmt.Fprintf(w, getOrderResponse, "1", "")
+	})
+	defer ts.Close()
+
+	expiring := testAssertion("_first", time.Now().Add(30*time.Second))
+	refreshed := 0
+	token := &SAMLToken{
+		Assertion: expiring,
+		Refresh: func() ([]byte, error) {
+			refreshed++
+			return testAssertion(fmt.Sprintf("_refreshed%d", refreshed), time.Now().Add(time.Hour)), nil
+		},
+		TokenReference: true,
+	}
+
+	c, err := NewClient(ts.URL+"?wsdl", WithSAMLToken(token))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	c.PayloadFormat = &PayloadFormat{}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Call("GetOrder", Params{"orderId": "1"}); err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+	}
+
+	// the first assertion expires within a minute, it's refreshed once
+	if refreshed != 1 {
+		t.Errorf("one refresh expected, got %d", refreshed)
+	}
+
+	assertion := testAssertion("_refreshed1", time.Time{})
+	assertion = assertion[bytes.Index(assertion, []byte("<saml2:Assertion")):]
+	assertion = assertion[:bytes.Index(assertion, []byte("NotOnOrAfter="))]
+
+	for _, b := range bodies {
+		for _, want := range []string{
+			`<soap:Header><wsse:Security xmlns:wsse="` + wsseNamespace + `" soap:mustUnderstand="1">` + string(assertion),
+			`</saml2:Assertion><wsse:SecurityTokenReference xmlns:wsse="` + wsseNamespace + `" xmlns:wsse11="` + wsse11Namespace + `" xmlns:wsu="` + wsuNamespace + `" wsu:Id="STR-`,
+			`wsse11:TokenType="` + samlV20TokenType + `"><wsse:KeyIdentifier ValueType="` + samlIDValueType + `">_refreshed1</wsse:KeyIdentifier></wsse:SecurityTokenReference></wsse:Security></soap:Header><soap:Body>`,
+		} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("request must contain %s, got:\n%s", want, b)
+			}
+		}
+	}
+
+	ref, err := token.SecurityTokenReference()
+	if err != nil || !bytes.Contains(ref, []byte(">_refreshed1</wsse:KeyIdentifier>")) {
+		t.Errorf("unexpected reference %s: %v", ref, err)
+	}
+}
+
+func TestSAMLToken_assertion(t *testing.T) {
+	expired := &SAMLToken{Assertion: testAssertion("_old", time.Now().Add(-time.Second))}
+	if _, err := expired.assertion(); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expired error expected, got %v", err)
+	}
+
+	// without refresh, it's used until it expires
+	valid := &SAMLToken{Assertion: testAssertion("_valid", time.Now().Add(10*time.Second))}
+	if a, err := valid.assertion(); err != nil || a.id != "_valid" {
+		t.Errorf("assertion expected, got %v", err)
+	}
+
+	v11 := &SAMLToken{Assertion: []byte(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:1.0:assertion" AssertionID="_v11"/>`)}
+	if ref, err := v11.SecurityTokenReference(); err != nil || !bytes.Contains(ref, []byte(samlV11ValueType+`">_v11<`)) {
+		t.Errorf("unexpected reference %s: %v", ref, err)
+	}
+
+	for _, b := range []string{`<Assertion ID="x"/>`, `<saml2:Assertion xmlns:saml2="urn:oasis:names:tc:SAML:2.0:assertion"/>`, `<saml2:Assertion`} {
+		if _, err := (&SAMLToken{Assertion: []byte(b)}).assertion(); err == nil {
+			t.Errorf("error expected for %s", b)
+		}
+	}
+}
+
+func TestSAMLToken_Encryption(t *testing.T) {
+	cert, key := newTestCertificate(t, "server")
+	token := &SAMLToken{Assertion: testAssertion("_a", time.Now().Add(time.Hour))}
+
+	b, err := token.addTo([]byte(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><a>1</a></s:Body></s:Envelope>`))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	e := &Encryption{Recipient: cert, Key: key}
+	if b, err = e.EncryptEnvelope(b); err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if !regexp.MustCompile(`<s:Header><wsse:Security [^>]+><saml2:Assertion .*</saml2:Assertion><xenc:EncryptedKey .*</xenc:EncryptedKey></wsse:Security></s:Header>`).Match(bytes.ReplaceAll(b, []byte("\n"), nil)) {
+		t.Errorf("assertion and key expected in one security header:\n%s", b)
+	}
+}
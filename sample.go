@@ -0,0 +1,151 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// SampleParams returns skeleton Params for the operation with every element of its
+// input, filled with placeholder values by type. Repeated elements hold a single entry
+func (wsdl *wsdlDefinitions) SampleParams(operation string) (Params, error) {
+	s, err := wsdl.InputSchema(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Params{}
+	for _, c := range s.Children {
+		p[c.Name] = sampleValue(c)
+	}
+
+	return p, nil
+}
+
+// SampleRequest returns the envelope of a request to the operation with every element
+// of its input, filled with placeholder values by type. Optional and repeated elements
+// are preceded by a comment
+func (c *Client) SampleRequest(operation string) ([]byte, error) {
+	if err := c.LoadDefinitions(); err != nil {
+		return nil, err
+	}
+
+	s, err := c.Definitions.InputSchema(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := c.newTokenData(c.Definitions.schema(s.Namespace))
+	tokens.startEnvelope()
+	if len(c.HeaderParams) > 0 {
+		tokens.startHeader(c.HeaderName, s.Namespace)
+		tokens.recursiveEncode(c.HeaderParams, nil)
+		tokens.endHeader(c.HeaderName)
+	}
+
+	if err := tokens.startBody(operation, s.Namespace); err != nil {
+		return nil, err
+	}
+
+	for _, e := range s.Children {
+		tokens.sampleEncode(e)
+	}
+
+	tokens.endBody(operation)
+	tokens.endEnvelope()
+
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	e.Indent("", "    ")
+	for _, t := range tokens.data {
+		if err := e.EncodeToken(t); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (tokens *tokenData) sampleEncode(s *SchemaElement) {
+	if c := occursComment(s); c != "" {
+		tokens.data = append(tokens.data, xml.Comment(c))
+	}
+
+	t := xml.StartElement{Name: tokens.paramName(s.Name)}
+	tokens.data = append(tokens.data, t)
+	if len(s.Children) == 0 {
+		tokens.data = append(tokens.data, xml.CharData(samplePlaceholder(s)))
+	}
+
+	for _, c := range s.Children {
+		tokens.sampleEncode(c)
+	}
+
+	tokens.data = append(tokens.data, xml.EndElement{Name: t.Name})
+}
+
+func sampleValue(s *SchemaElement) interface{} {
+	var v interface{} = samplePlaceholder(s)
+	if len(s.Children) > 0 {
+		p := Params{}
+		for _, c := range s.Children {
+			p[c.Name] = sampleValue(c)
+		}
+		v = p
+	}
+
+	if s.IsRepeated() {
+		return []interface{}{v}
+	}
+
+	return v
+}
+
+func occursComment(s *SchemaElement) string {
+	switch {
+	case s.IsRepeated() && s.MaxOccurs == Unbounded:
+		return fmt.Sprintf("%d or more repetitions:", s.MinOccurs)
+	case s.IsRepeated():
+		return fmt.Sprintf("%d to %d repetitions:", s.MinOccurs, s.MaxOccurs)
+	case s.IsOptional():
+		return "Optional:"
+	}
+
+	return ""
+}
+
+// samplePlaceholder returns a value of the element type, the first one
+// of the enumeration if it's restricted to some
+func samplePlaceholder(s *SchemaElement) string {
+	if s.Restriction != nil && len(s.Restriction.Enumeration) > 0 {
+		return s.Restriction.Enumeration[0]
+	}
+
+	switch localName(s.Type) {
+	case "int", "integer", "long", "short", "byte", "unsignedInt", "unsignedLong", "unsignedShort", "unsignedByte",
+		"nonNegativeInteger", "nonPositiveInteger":
+		return "0"
+	case "positiveInteger":
+		return "1"
+	case "negativeInteger":
+		return "-1"
+	case "decimal", "float", "double":
+		return "0.0"
+	case "boolean":
+		return "false"
+	case "date":
+		return "2006-01-02"
+	case "dateTime":
+		return "2006-01-02T15:04:05Z"
+	case "time":
+		return "15:04:05"
+	case "base64Binary":
+		return ""
+	}
+
+	return "?"
+}
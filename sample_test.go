@@ -0,0 +1,58 @@
+package gosoap
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDefinitions_SampleParams(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	p, err := d.SampleParams("CreateOrder")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if p["customerId"] != "?" || p["express"] != "false" {
+		t.Errorf("unexpected placeholders: %v", p)
+	}
+
+	items, ok := p["item"].([]interface{})
+	if !ok || len(items) != 1 {
+		t.Fatalf("item must be a list with one entry: %v", p["item"])
+	}
+
+	if item := items[0].(Params); item["sku"] != "?" || item["quantity"] != "0" || item["price"] != "0.0" {
+		t.Errorf("unexpected item: %v", item)
+	}
+
+	if _, err := d.SampleParams("DeleteOrder"); err == nil {
+		t.Errorf("error expected for unknown operation")
+	}
+}
+
+func TestClient_SampleRequest(t *testing.T) {
+	dir, _ := os.Getwd()
+	c, err := SoapClient(fmt.Sprintf("file://%s/testdata/orders.wsdl", dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := c.SampleRequest("CreateOrder")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	for _, want := range []string{
+		`<CreateOrder xmlns="http://example.com/orders">`,
+		"<!--1 or more repetitions:-->\n            <item>",
+		"<!--Optional:-->\n                <zip>?</zip>",
+		"<quantity>0</quantity>",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("sample must contain %q, got:\n%s", want, b)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package gosoap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Unbounded is the MaxOccurs value of elements declared with maxOccurs="unbounded"
+const Unbounded = -1
+
+// SchemaElement describes an element of an operation message as declared in the wsdl types
+type SchemaElement struct {
+	Name      string
+	Namespace string
+	Type      string
+	MinOccurs int
+	MaxOccurs int
+	Nillable  bool
+	Children  []*SchemaElement
+	// Restriction holds the facets of simple types, nil if there are none
+	Restriction *SchemaRestriction
+}
+
+// SchemaRestriction holds the facets restricting the values of a simple type,
+// empty strings are facets not set. Patterns holds a pattern per derivation step,
+// all of them must match
+type SchemaRestriction struct {
+	Base         string
+	Enumeration  []string
+	Patterns     []string
+	MinInclusive string
+	MaxInclusive string
+	Length       string
+	MinLength    string
+	MaxLength    string
+
+	// patterns are the compiled Patterns, nil for the ones without a regexp equivalent
+	patterns []*regexp.Regexp
+}
+
+// IsOptional reports whether the element may be omitted
+func (e *SchemaElement) IsOptional() bool {
+	return e.MinOccurs == 0
+}
+
+// IsRepeated reports whether the element may appear more than once
+func (e *SchemaElement) IsRepeated() bool {
+	return e.MaxOccurs == Unbounded || e.MaxOccurs > 1
+}
+
+// Child returns the direct child with the name n or nil
+func (e *SchemaElement) Child(n string) *SchemaElement {
+	for _, c := range e.Children {
+		if c.Name == n {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// OperationNames returns the names of the operations of the default port type
+func (wsdl *wsdlDefinitions) OperationNames() []string {
+	pt := wsdl.portType()
+	if pt == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(pt.Operations))
+	for _, o := range pt.Operations {
+		names = append(names, o.Name)
+	}
+
+	return names
+}
+
+// InputSchema returns the element tree expected in the body of the operation request
+func (wsdl *wsdlDefinitions) InputSchema(operation string) (*SchemaElement, error) {
+	o := wsdl.operation(operation)
+	if o == nil {
+		return nil, fmt.Errorf("operation %q not found in wsdl definitions", operation)
+	}
+
+	if len(o.Inputs) == 0 {
+		return nil, fmt.Errorf("operation %q has no input message", operation)
+	}
+
+	return wsdl.messageSchema(operation, o.Inputs[0].Message)
+}
+
+// OutputSchema returns the element tree expected in the body of the operation response
+func (wsdl *wsdlDefinitions) OutputSchema(operation string) (*SchemaElement, error) {
+	o := wsdl.operation(operation)
+	if o == nil {
+		return nil, fmt.Errorf("operation %q not found in wsdl definitions", operation)
+	}
+
+	if len(o.Outputs) == 0 {
+		return nil, fmt.Errorf("operation %q has no output message", operation)
+	}
+
+	return wsdl.messageSchema(operation+"Response", o.Outputs[0].Message)
+}
+
+// port returns the service port used by Client.Do, the first one with a soap address.
+// Ports bound to SOAP 1.1 are preferred, a SOAP 1.2 one is used when there's no other
+func (wsdl *wsdlDefinitions) port() *wsdlPort {
+	var fallback *wsdlPort
+	for _, s := range wsdl.Services {
+		for _, p := range s.Ports {
+			if len(p.SoapAddresses) == 0 {
+				continue
+			}
+
+			if wsdl.bindingVersion(p.Binding) == "1.2" {
+				if fallback == nil {
+					fallback = p
+				}
+				continue
+			}
+
+			return p
+		}
+	}
+
+	return fallback
+}
+
+// bindingVersion returns the SOAP version of the binding name, 1.1 or 1.2
+func (wsdl *wsdlDefinitions) bindingVersion(name string) string {
+	if b := wsdl.binding(name); b != nil && len(b.SoapBindings) > 0 && b.SoapBindings[0].Version == "1.2" {
+		return "1.2"
+	}
+
+	return "1.1"
+}
+
+// soapVersion returns the SOAP version of the envelopes sent to the port used by Client.Do
+func (wsdl *wsdlDefinitions) soapVersion() string {
+	if wsdl == nil {
+		return "1.1"
+	}
+
+	if p := wsdl.port(); p != nil {
+		return wsdl.bindingVersion(p.Binding)
+	}
+
+	return "1.1"
+}
+
+// Endpoint returns the soap address of the port used by Client.Do
+func (wsdl *wsdlDefinitions) Endpoint() (string, error) {
+	p := wsdl.port()
+	if p == nil {
+		return "", fmt.Errorf("no soap address found in wsdl definitions")
+	}
+
+	return p.SoapAddresses[0].Location, nil
+}
+
+// portBinding returns the binding of the port used by Client.Do
+func (wsdl *wsdlDefinitions) portBinding() *wsdlBinding {
+	if p := wsdl.port(); p != nil {
+		return wsdl.binding(p.Binding)
+	}
+
+	if len(wsdl.Services) > 0 && len(wsdl.Services[0].Ports) > 0 {
+		return wsdl.binding(wsdl.Services[0].Ports[0].Binding)
+	}
+
+	return nil
+}
+
+// portType returns the port type bound to the port used by Client.Do
+func (wsdl *wsdlDefinitions) portType() *wsdlPortTypes {
+	if len(wsdl.PortTypes) == 0 {
+		return nil
+	}
+
+	if b := wsdl.portBinding(); b != nil {
+		for _, pt := range wsdl.PortTypes {
+			if pt.Name == localName(b.Type) {
+				return pt
+			}
+		}
+	}
+
+	return wsdl.PortTypes[0]
+}
+
+func (wsdl *wsdlDefinitions) binding(name string) *wsdlBinding {
+	for _, b := range wsdl.Bindings {
+		if b.Name == localName(name) {
+			return b
+		}
+	}
+
+	return nil
+}
+
+func (wsdl *wsdlDefinitions) operation(name string) *wsdlOperation {
+	pt := wsdl.portType()
+	if pt == nil {
+		return nil
+	}
+
+	for _, o := range pt.Operations {
+		if o.Name == name {
+			return o
+		}
+	}
+
+	return nil
+}
+
+func (wsdl *wsdlDefinitions) message(name string) *wsdlMessage {
+	for _, m := range wsdl.Messages {
+		if m.Name == localName(name) {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// messageSchema builds the element tree of the message m. Document style messages
+// reference a single element, rpc style ones are wrapped in an element named w
+func (wsdl *wsdlDefinitions) messageSchema(w, m string) (*SchemaElement, error) {
+	msg := wsdl.message(m)
+	if msg == nil {
+		return nil, fmt.Errorf("message %q not found in wsdl definitions", m)
+	}
+
+	if len(msg.Parts) == 1 && msg.Parts[0].Element != "" {
+		el, ns := wsdl.element(msg.Parts[0].Element)
+		if el == nil {
+			return nil, fmt.Errorf("element %q not found in wsdl types", msg.Parts[0].Element)
+		}
+
+		return wsdl.buildSchema(el, ns, map[string]bool{elementKey(ns, el.Name): true}), nil
+	}
+
+	root := &SchemaElement{Name: w, Namespace: wsdl.TargetNamespace, MinOccurs: 1, MaxOccurs: 1}
+	for _, p := range msg.Parts {
+		el := &xsdElement{Name: p.Name, Type: p.Type}
+		ns := ""
+		if p.Element != "" {
+			el, ns = wsdl.element(p.Element)
+			if el == nil {
+				return nil, fmt.Errorf("element %q not found in wsdl types", p.Element)
+			}
+		}
+
+		seen := map[string]bool{}
+		if p.Element != "" {
+			seen[elementKey(ns, el.Name)] = true
+		}
+		root.Children = append(root.Children, wsdl.buildSchema(el, ns, seen))
+	}
+
+	return root, nil
+}
+
+// buildSchema resolves references and named types of el. seen guards
+// against recursive type definitions
+func (wsdl *wsdlDefinitions) buildSchema(el *xsdElement, ns string, seen map[string]bool) *SchemaElement {
+	s := &SchemaElement{
+		Name:      el.Name,
+		Namespace: ns,
+		Type:      el.Type,
+		MinOccurs: parseOccurs(el.MinOccurs),
+		MaxOccurs: parseOccurs(el.MaxOccurs),
+		Nillable:  el.Nillable,
+	}
+
+	if el.Ref != "" {
+		ref, refNs := wsdl.element(el.Ref)
+		if ref == nil {
+			s.Name = localName(el.Ref)
+			return s
+		}
+
+		el, ns = ref, refNs
+		s.Name, s.Namespace, s.Type, s.Nillable = el.Name, ns, el.Type, el.Nillable
+
+		// elements may reference themselves from their inline type
+		key := elementKey(ns, el.Name)
+		if seen[key] {
+			return s
+		}
+		seen[key] = true
+		defer delete(seen, key)
+	}
+
+	ct, st := el.ComplexType, el.SimpleType
+	if ct == nil && st == nil && el.Type != "" && !isXsdType(el.Type) {
+		ct, _ = wsdl.complexType(el.Type)
+		if ct == nil {
+			st = wsdl.simpleType(el.Type)
+		}
+	}
+
+	if st != nil && st.Sequence != nil {
+		s.Restriction = wsdl.restriction(st.Sequence)
+		s.Type = s.Restriction.Base
+	}
+
+	if ct == nil || ct.Sequence == nil {
+		return s
+	}
+
+	if el.Type != "" {
+		if seen[el.Type] {
+			return s
+		}
+		seen[el.Type] = true
+		defer delete(seen, el.Type)
+	}
+
+	for _, c := range ct.Sequence.Elements {
+		s.Children = append(s.Children, wsdl.buildSchema(c, ns, seen))
+	}
+
+	return s
+}
+
+// elementKey identifies the top level element name of the namespace ns in the seen
+// elements of buildSchema
+func elementKey(ns, name string) string {
+	return "ref:{" + ns + "}" + name
+}
+
+// restriction collects the facets of r and of the simple types it's derived from,
+// facets of derived types take precedence. Base is set to the builtin type
+func (wsdl *wsdlDefinitions) restriction(r *xsdRestriction) *SchemaRestriction {
+	sr := &SchemaRestriction{}
+	seen := map[string]bool{}
+
+	for r != nil {
+		sr.Base = r.Base
+		if len(sr.Enumeration) == 0 {
+			for _, e := range r.Enumerations {
+				sr.Enumeration = append(sr.Enumeration, e.Value)
+			}
+		}
+		if len(r.Patterns) > 0 {
+			// patterns of the same step are alternatives
+			alt := make([]string, len(r.Patterns))
+			for i, p := range r.Patterns {
+				alt[i] = p.Value
+			}
+			sr.Patterns = append(sr.Patterns, strings.Join(alt, "|"))
+			sr.patterns = append(sr.patterns, wsdl.pattern(sr.Patterns[len(sr.Patterns)-1]))
+		}
+		if sr.MinInclusive == "" && r.MinInclusive != nil {
+			sr.MinInclusive = r.MinInclusive.Value
+		}
+		if sr.MaxInclusive == "" && r.MaxInclusive != nil {
+			sr.MaxInclusive = r.MaxInclusive.Value
+		}
+		if sr.Length == "" && r.Length != nil {
+			sr.Length = r.Length.Value
+		}
+		if sr.MinLength == "" && r.MinLength != nil {
+			sr.MinLength = r.MinLength.Value
+		}
+		if sr.MaxLength == "" && r.MaxLength != nil {
+			sr.MaxLength = r.MaxLength.Value
+		}
+
+		if isXsdType(r.Base) || seen[r.Base] {
+			break
+		}
+		seen[r.Base] = true
+
+		st := wsdl.simpleType(r.Base)
+		if st == nil {
+			break
+		}
+		r = st.Sequence
+	}
+
+	return sr
+}
+
+// schema returns the schema of the namespace ns
+func (wsdl *wsdlDefinitions) schema(ns string) *xsdSchema {
+	for _, t := range wsdl.Types {
+		for _, s := range t.XsdSchema {
+			if s.TargetNamespace == ns {
+				return s
+			}
+		}
+	}
+
+	return &xsdSchema{TargetNamespace: ns}
+}
+
+// element returns the top level element named name and the namespace of its schema
+func (wsdl *wsdlDefinitions) element(name string) (*xsdElement, string) {
+	for _, t := range wsdl.Types {
+		for _, s := range t.XsdSchema {
+			for _, e := range s.Elements {
+				if e.Name == localName(name) {
+					return e, s.TargetNamespace
+				}
+			}
+		}
+	}
+
+	return nil, ""
+}
+
+func (wsdl *wsdlDefinitions) complexType(name string) (*xsdComplexType, string) {
+	for _, t := range wsdl.Types {
+		for _, s := range t.XsdSchema {
+			for _, ct := range s.ComplexTypes {
+				if ct.Name == localName(name) {
+					return ct, s.TargetNamespace
+				}
+			}
+		}
+	}
+
+	return nil, ""
+}
+
+func (wsdl *wsdlDefinitions) simpleType(name string) *xsdSimpleType {
+	for _, t := range wsdl.Types {
+		for _, s := range t.XsdSchema {
+			for _, st := range s.SimpleTypes {
+				if st.Name == localName(name) {
+					return st
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isXsdType reports whether t is one of the builtin xml schema types,
+// assuming the usual prefixes are bound to the XMLSchema namespace
+func isXsdType(t string) bool {
+	i := strings.Index(t, ":")
+	if i < 0 {
+		return false
+	}
+
+	switch t[:i] {
+	case "s", "xs", "xsd":
+		return true
+	}
+
+	return false
+}
+
+// localName strips the namespace prefix of a qualified name
+func localName(qname string) string {
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+
+	return qname
+}
+
+// parseOccurs converts minOccurs and maxOccurs values, both default to 1
+func parseOccurs(v string) int {
+	if v == "" {
+		return 1
+	}
+
+	if v == "unbounded" {
+		return Unbounded
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 1
+	}
+
+	return n
+}
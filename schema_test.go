@@ -0,0 +1,97 @@
+package gosoap
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func loadTestDefinitions(t *testing.T, wsdl string) *wsdlDefinitions {
+	dir, _ := os.Getwd()
+
+	c, err := SoapClient(fmt.Sprintf("file://%s/testdata/%s", dir, wsdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.LoadDefinitions(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c.Definitions
+}
+
+func TestDefinitions_InputSchema(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	if names := d.OperationNames(); len(names) != 2 || names[0] != "CreateOrder" || names[1] != "GetOrder" {
+		t.Errorf("unexpected operations: %v", names)
+	}
+
+	s, err := d.InputSchema("CreateOrder")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if s.Name != "CreateOrder" || s.Namespace != "http://example.com/orders" || len(s.Children) != 5 {
+		t.Fatalf("unexpected schema: %+v", s)
+	}
+
+	item := s.Child("item")
+	if item == nil || !item.IsRepeated() || item.IsOptional() {
+		t.Fatalf("unexpected item: %+v", item)
+	}
+
+	if q := item.Child("quantity"); q == nil || q.Type != "xs:int" {
+		t.Errorf("quantity must resolve to the simple type base: %+v", q)
+	}
+
+	if zip := s.Child("address").Child("zip"); zip == nil || !zip.IsOptional() {
+		t.Errorf("unexpected zip: %+v", zip)
+	}
+
+	out, err := d.OutputSchema("GetOrder")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if out.Name != "GetOrderResponse" || out.Child("address") == nil {
+		t.Errorf("unexpected output schema: %+v", out)
+	}
+
+	if _, err := d.InputSchema("DeleteOrder"); err == nil {
+		t.Errorf("error expected for unknown operation")
+	}
+}
+
+func TestDefinitions_RecursiveRef(t *testing.T) {
+	d, err := parseWsdl([]byte(`<wsdl:definitions xmlns:wsdl="http://schemas.xmlsoap.org/wsdl/" xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:tns="urn:tree" targetNamespace="urn:tree">
+  <wsdl:types>
+    <xs:schema targetNamespace="urn:tree">
+      <xs:element name="node">
+        <xs:complexType>
+          <xs:sequence>
+            <xs:element name="value" type="xs:string" />
+            <xs:element ref="tns:node" minOccurs="0" maxOccurs="unbounded" />
+          </xs:sequence>
+        </xs:complexType>
+      </xs:element>
+    </xs:schema>
+  </wsdl:types>
+  <wsdl:message name="In"><wsdl:part name="parameters" element="tns:node" /></wsdl:message>
+  <wsdl:portType name="TreePortType"><wsdl:operation name="Put"><wsdl:input message="tns:In" /></wsdl:operation></wsdl:portType>
+</wsdl:definitions>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.InputSchema("Put")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	child := s.Child("node")
+	if child == nil || !child.IsRepeated() || len(child.Children) != 0 {
+		t.Errorf("recursion must stop at the referenced element, got %+v", child)
+	}
+}
@@ -0,0 +1,225 @@
+package gosoap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	wsseNamespace   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	wsse11Namespace = "http://docs.oasis-open.org/wss/oasis-wss-wssecurity-secext-1.1.xsd"
+	wsuNamespace    = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	xencNamespace   = "http://www.w3.org/2001/04/xmlenc#"
+	dsNamespace     = "http://www.w3.org/2000/09/xmldsig#"
+
+	wsseBase64Binary = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary"
+)
+
+// envelopeLayout holds the byte offsets of the parts of an envelope the WS-Security
+// stages change. Envelopes are changed by splicing bytes, so the rest of the
+// envelope, signed content included, is kept as is
+type envelopeLayout struct {
+	b []byte
+	// soapPrefix of the envelope elements, with the colon
+	soapPrefix string
+	// header holds the offsets of soap:Header, security of wsse:Security in it, start
+	// is -1 if there's none
+	header, security, body elementOffsets
+}
+
+// elementOffsets of an element: start of the start tag, start and end of the content,
+// end of the end tag. A self-closing element has contentStart == end
+type elementOffsets struct {
+	start, contentStart, contentEnd, end int
+}
+
+func (o elementOffsets) selfClosing() bool {
+	return o.contentStart == o.end
+}
+
+// xmlDeclaration matches the declaration of an xml document naming its encoding
+var xmlDeclaration = regexp.MustCompile(`^\s*<\?xml\s[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:-]+)["'][^>]*\?>`)
+
+// utf8Envelope returns the envelope b in UTF-8. The xml declaration of other charsets
+// is removed, so the result is read as UTF-8
+func utf8Envelope(b []byte) ([]byte, error) {
+	m := xmlDeclaration.FindSubmatchIndex(b)
+	if m == nil {
+		return b, nil
+	}
+
+	label := string(b[m[2]:m[3]])
+	enc, name := charset.Lookup(label)
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported charset %q", label)
+	}
+	if name == "utf-8" {
+		return b, nil
+	}
+
+	return enc.NewDecoder().Bytes(b[m[1]:])
+}
+
+// parseEnvelope returns the layout of the envelope b. Only UTF-8 envelopes are supported,
+// see utf8Envelope
+func parseEnvelope(b []byte) (*envelopeLayout, error) {
+	l := &envelopeLayout{
+		b:        b,
+		header:   elementOffsets{start: -1},
+		security: elementOffsets{start: -1},
+		body:     elementOffsets{start: -1},
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	var (
+		path []xml.Name
+		open []*elementOffsets
+	)
+	for {
+		before := int(d.InputOffset())
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("envelope can't be parsed: %s", err)
+		}
+		after := int(d.InputOffset())
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name)
+
+			var o *elementOffsets
+			switch {
+			case len(path) == 1 && t.Name.Local == "Envelope":
+				if i := bytes.IndexAny(b[before+1:after], ":> \t\r\n/"); i >= 0 && b[before+1+i] == ':' {
+					l.soapPrefix = string(b[before+1:before+1+i]) + ":"
+				}
+			case len(path) == 2 && t.Name.Local == "Header" && t.Name.Space == path[0].Space:
+				o = &l.header
+			case len(path) == 2 && t.Name.Local == "Body" && t.Name.Space == path[0].Space:
+				o = &l.body
+			case len(path) == 3 && path[1].Local == "Header" && t.Name.Space == wsseNamespace && t.Name.Local == "Security" && l.security.start < 0:
+				o = &l.security
+			}
+
+			if o != nil {
+				o.start, o.contentStart = before, after
+			}
+			open = append(open, o)
+		case xml.EndElement:
+			if o := open[len(open)-1]; o != nil {
+				o.contentEnd, o.end = before, after
+				if before == after {
+					// self-closing, the content is empty
+					o.contentStart, o.contentEnd = after, after
+				}
+			}
+
+			path, open = path[:len(path)-1], open[:len(open)-1]
+		}
+	}
+
+	if l.body.start < 0 {
+		return nil, fmt.Errorf("envelope has no Body")
+	}
+
+	return l, nil
+}
+
+// splice returns b with the bytes from start to end replaced by s
+func splice(b []byte, start, end int, s ...[]byte) []byte {
+	out := make([]byte, 0, len(b)+len(bytes.Join(s, nil)))
+	out = append(out, b[:start]...)
+	for _, p := range s {
+		out = append(out, p...)
+	}
+
+	return append(out, b[end:]...)
+}
+
+// openSelfClosing returns the start tag of a self-closing element as a start tag
+func openSelfClosing(tag []byte) []byte {
+	return append(bytes.TrimRight(bytes.TrimSuffix(tag, []byte("/>")), " \t\r\n"), '>')
+}
+
+// withSecurity returns the envelope with the entries appended to its wsse:Security
+// header, which is added if missing
+func (l *envelopeLayout) withSecurity(entries []byte) []byte {
+	b := l.b
+	p := l.soapPrefix
+
+	if s := l.security; s.start >= 0 {
+		if s.selfClosing() {
+			return splice(b, s.start, s.end, openSelfClosing(b[s.start:s.end]), entries, []byte("</"), tagName(b[s.start:s.end]), []byte(">"))
+		}
+		return splice(b, s.contentEnd, s.contentEnd, entries)
+	}
+
+	security := []byte(fmt.Sprintf(`<wsse:Security xmlns:wsse="%s" %smustUnderstand="1">%s</wsse:Security>`, wsseNamespace, p, entries))
+	if p == "" {
+		// the default namespace is the soap one, mustUnderstand must not be qualified by it
+		security = []byte(fmt.Sprintf(`<wsse:Security xmlns:wsse="%s">%s</wsse:Security>`, wsseNamespace, entries))
+	}
+
+	if h := l.header; h.start >= 0 {
+		if h.selfClosing() {
+			return splice(b, h.start, h.end, openSelfClosing(b[h.start:h.end]), security, []byte("</"+p+"Header>"))
+		}
+		return splice(b, h.contentEnd, h.contentEnd, security)
+	}
+
+	return splice(b, l.body.start, l.body.start, []byte("<"+p+"Header>"), security, []byte("</"+p+"Header>"))
+}
+
+// withBodyContent returns the envelope with the content of the body replaced by c
+func (l *envelopeLayout) withBodyContent(c []byte) []byte {
+	o := l.body
+	if o.selfClosing() {
+		return splice(l.b, o.start, o.end, openSelfClosing(l.b[o.start:o.end]), c, []byte("</"+l.soapPrefix+"Body>"))
+	}
+
+	return splice(l.b, o.contentStart, o.contentEnd, c)
+}
+
+// bodyContent returns the content of the body
+func (l *envelopeLayout) bodyContent() []byte {
+	return l.b[l.body.contentStart:l.body.contentEnd]
+}
+
+// tagName returns the qualified name of the start tag
+func tagName(tag []byte) []byte {
+	tag = bytes.TrimPrefix(tag, []byte("<"))
+	if i := bytes.IndexAny(tag, " \t\r\n/>"); i >= 0 {
+		return tag[:i]
+	}
+
+	return tag
+}
+
+// newSecurityID returns a new Id attribute value with the prefix
+func newSecurityID(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return prefix + "-" + hex.EncodeToString(b), nil
+}
+
+// escapeXML escapes s for text and attribute values
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
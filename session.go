@@ -0,0 +1,228 @@
+package gosoap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Session logs in with an operation of the Client and injects the token found in
+// its response into the requests made with Call and Do. The token may be sent as a
+// cookie, an http header or a body param, any combination of them
+type Session struct {
+	Client *Client
+	// LoginOperation is called with LoginParams and LoginArrayParams to get the token
+	LoginOperation   string
+	LoginParams      Params
+	LoginArrayParams ArrayParams
+	// TokenPath locates the token in the login response body, with the local names of
+	// the elements separated by slashes, e.g. "loginResponse/sid"
+	TokenPath string
+	// Cookie is the name of the cookie holding the token
+	Cookie string
+	// Header is the name of the http header holding the token
+	Header string
+	// Param is the name of the body param holding the token. It's set in Params, following
+	// the schema sequence like the other keys, or sent first with ArrayParams
+	Param string
+	// Relogin reports whether a fault means the token expired, the login is then
+	// done again and the request retried once. Faults are returned as is if nil
+	Relogin func(*FaultError) bool
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewSession return new *Session logging in with the operation op of c called with p
+func NewSession(c *Client, op string, p Params, tokenPath string) *Session {
+	return &Session{Client: c, LoginOperation: op, LoginParams: p, TokenPath: tokenPath}
+}
+
+// Token returns the current token, empty before the login
+func (s *Session) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.token
+}
+
+// Login calls the login operation and keeps the token of its response
+func (s *Session) Login() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.login()
+}
+
+func (s *Session) login() error {
+	res, err := s.Client.Do(&Request{Method: s.LoginOperation, Params: s.LoginParams, ArrayParams: s.LoginArrayParams})
+	if err != nil {
+		return err
+	}
+
+	if err := res.fault(); err != nil {
+		return err
+	}
+
+	token, err := tokenAt(res.Body, s.TokenPath)
+	if err != nil {
+		return err
+	}
+
+	s.token = token
+	return nil
+}
+
+// relogin logs in again unless the token was already renewed since old was used
+func (s *Session) relogin(old string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == old {
+		if err := s.login(); err != nil {
+			return "", err
+		}
+	}
+
+	return s.token, nil
+}
+
+// current returns the token, logging in first if needed
+func (s *Session) current() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" {
+		if err := s.login(); err != nil {
+			return "", err
+		}
+	}
+
+	return s.token, nil
+}
+
+// Call call's the method m with Params p and the session token
+func (s *Session) Call(m string, p Params) (*Response, error) {
+	return s.Do(NewRequest(m, p))
+}
+
+// CallArray call's the method m with ArrayParams p and the session token
+func (s *Session) CallArray(m string, p ArrayParams) (*Response, error) {
+	return s.Do(NewArrayRequest(m, p))
+}
+
+// Do sends req with the session token, logging in first if needed
+func (s *Session) Do(req *Request) (*Response, error) {
+	token, err := s.current()
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+
+	r, err := s.withToken(req, token)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.Client.Do(r)
+	if err != nil || s.Relogin == nil {
+		return res, err
+	}
+
+	var fault *FaultError
+	if !errors.As(res.fault(), &fault) || !s.Relogin(fault) {
+		return res, nil
+	}
+
+	token, err = s.relogin(token)
+	if err != nil {
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+
+	if r, err = s.withToken(req, token); err != nil {
+		return nil, err
+	}
+
+	return s.Client.Do(r)
+}
+
+// withToken returns a copy of req holding the token
+func (s *Session) withToken(req *Request, token string) (*Request, error) {
+	r := *req
+
+	if s.Cookie != "" || s.Header != "" {
+		r.HTTPHeader = req.HTTPHeader.Clone()
+		if r.HTTPHeader == nil {
+			r.HTTPHeader = http.Header{}
+		}
+	}
+	if s.Cookie != "" {
+		r.HTTPHeader.Add("Cookie", (&http.Cookie{Name: s.Cookie, Value: token}).String())
+	}
+	if s.Header != "" {
+		r.HTTPHeader.Set(s.Header, token)
+	}
+
+	if s.Param == "" {
+		return &r, nil
+	}
+
+	if req.Body != "" {
+		return nil, fmt.Errorf("token param %q can't be added to a raw body", s.Param)
+	}
+
+	if req.Params == nil && req.ArrayParams != nil {
+		r.ArrayParams = ArrayParams{{s.Param, token}}
+		for _, p := range req.ArrayParams {
+			if p[0] != s.Param {
+				r.ArrayParams = append(r.ArrayParams, p)
+			}
+		}
+
+		return &r, nil
+	}
+
+	r.Params = make(Params, len(req.Params)+1)
+	for k, v := range req.Params {
+		r.Params[k] = v
+	}
+	r.Params[s.Param] = token
+
+	return &r, nil
+}
+
+// tokenAt returns the text of the element at path in the body
+func tokenAt(body []byte, path string) (string, error) {
+	n, err := parseNode(body)
+	if err != nil {
+		return "", err
+	}
+
+	names := strings.Split(strings.Trim(path, "/"), "/")
+	if n == nil || n.name != names[0] {
+		return "", fmt.Errorf("token %q not found in login response", path)
+	}
+
+	for _, name := range names[1:] {
+		var next *node
+		for _, c := range n.children {
+			if c.name == name {
+				next = c
+				break
+			}
+		}
+
+		if next == nil {
+			return "", fmt.Errorf("token %q not found in login response", path)
+		}
+		n = next
+	}
+
+	token := strings.TrimSpace(n.text)
+	if token == "" {
+		return "", fmt.Errorf("token %q is empty in login response", path)
+	}
+
+	return token, nil
+}
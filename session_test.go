@@ -0,0 +1,159 @@
+package gosoap
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const sessionFault = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault><faultcode>soap:Client</faultcode><faultstring>Session expired</faultstring></soap:Fault></soap:Body></soap:Envelope>`
+
+func TestSession(t *testing.T) {
+	var logins, expired int32
+
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+
+		if strings.HasSuffix(r.Header.Get("SOAPAction"), "/Login") {
+			if !strings.Contains(body.String(), "<username>robert</username>") {
+				t.Errorf("unexpected login request %s", body.String())
+			}
+
+			n := atomic.AddInt32(&logins, 1)
+			fmt.Fprintf(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><LoginResponse><sid> sid-%d </sid></LoginResponse></soap:Body></soap:Envelope>`, n)
+			return
+		}
+
+		sid := fmt.Sprintf("sid-%d", atomic.LoadInt32(&logins))
+		if c, err := r.Cookie("SESSION"); err != nil || c.Value != sid {
+			t.Errorf("unexpected cookie %v", r.Header["Cookie"])
+		}
+		if got := r.Header.Get("X-Session"); got != sid {
+			t.Errorf("unexpected header %q", got)
+		}
+		if !strings.Contains(body.String(), "<sid>"+sid+"</sid>") {
+			t.Errorf("unexpected request %s", body.String())
+		}
+
+		if atomic.CompareAndSwapInt32(&expired, 0, 1) {
+			w.Write([]byte(sessionFault))
+			return
+		}
+
+		w.Write([]byte(fmt.Sprintf(getOrderResponse, "1", "")))
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	c.PayloadFormat = &PayloadFormat{}
+
+	s := NewSession(c, "Login", Params{"username": "robert", "password": "secret"}, "LoginResponse/sid")
+	s.Cookie, s.Header, s.Param = "SESSION", "X-Session", "sid"
+	s.Relogin = func(f *FaultError) bool {
+		return f.Description == "Session expired"
+	}
+
+	res, err := s.CallArray("GetOrder", ArrayParams{{"orderId", "1"}})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if err := res.fault(); err != nil {
+		t.Errorf("fault not expected: %s", err)
+	}
+	if logins != 2 || s.Token() != "sid-2" {
+		t.Errorf("login expected twice, got %d with token %q", logins, s.Token())
+	}
+
+	if _, err := s.Call("GetOrder", Params{"orderId": "1"}); err != nil {
+		t.Errorf("error not expected: %s", err)
+	}
+	if logins != 2 {
+		t.Errorf("token must be reused, got %d logins", logins)
+	}
+}
+
+func TestSession_LoginFailure(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><LoginResponse><status>denied</status></LoginResponse></soap:Body></soap:Envelope>`))
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	s := NewSession(c, "Login", nil, "LoginResponse/sid")
+	if _, err := s.Call("GetOrder", Params{"orderId": "1"}); err == nil || !strings.Contains(err.Error(), "login failed") {
+		t.Errorf("login error expected, got %v", err)
+	}
+}
+
+func TestSession_withToken(t *testing.T) {
+	s := &Session{Param: "sid"}
+
+	p := Params{"b": "2", "a": "1", "sid": "old"}
+	r, err := s.withToken(NewRequest("GetOrder", p), "t")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if r.ArrayParams != nil || len(r.Params) != 3 || r.Params["sid"] != "t" || p["sid"] != "old" {
+		t.Errorf("token set in a copy of the params expected, got %v", r.Params)
+	}
+
+	r, err = s.withToken(NewArrayRequest("GetOrder", ArrayParams{{"b", "2"}, {"sid", "old"}, {"a", "1"}}), "t")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if got := fmt.Sprint(r.ArrayParams); r.Params != nil || got != "[[sid t] [b 2] [a 1]]" {
+		t.Errorf("token first and params in order expected, got %s", got)
+	}
+
+	if _, err := s.withToken(NewRawRequest("GetOrder", RawXML("<GetOrder/>")), "t"); err == nil {
+		t.Error("error expected for a raw body")
+	}
+}
+
+func TestSession_withTokenSequence(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/orders.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Replace(data, []byte(`<xs:element name="customerId" type="xs:string" />`),
+		[]byte(`<xs:element name="customerId" type="xs:string" /><xs:element name="sid" type="xs:string" />`), 1)
+
+	d, err := parseWsdl(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Session{Param: "sid"}
+	r, err := s.withToken(NewRequest("CreateOrder", Params{"note": "n", "address": Params{"city": "Lisbon", "street": "Main"}, "customerId": "1"}), "t")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	b, err := DefaultPayloadFormat.marshal(&process{Client: &Client{Definitions: d}, Request: r})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	order := []string{"<customerId>1</customerId>", "<sid>t</sid>", "<street>Main</street>", "<city>Lisbon</city>", "<note>n</note>"}
+	last := -1
+	for _, e := range order {
+		i := strings.Index(string(b), e)
+		if i < 0 || i < last {
+			t.Fatalf("elements in the schema order %v expected, got %s", order, b)
+		}
+		last = i
+	}
+}
@@ -2,12 +2,14 @@ package gosoap
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -21,6 +23,10 @@ type HeaderParams map[string]interface{}
 // Params type is used to set the params in soap request
 type Params map[string]interface{}
 
+// ArrayParams holds name and value pairs, encoded in order. Unlike Params
+// the same name may be given more than once
+type ArrayParams [][2]interface{}
+
 // SoapClient return new *Client to handle the requests with the WSDL
 func SoapClient(wsdl string) (*Client, error) {
 	_, err := url.Parse(wsdl)
@@ -32,6 +38,7 @@ func SoapClient(wsdl string) (*Client, error) {
 		wsdl:       wsdl,
 		HttpClient: &http.Client{},
 	}
+	c.Transport = &HTTPTransport{Client: c}
 
 	return c, nil
 }
@@ -48,12 +55,42 @@ type Client struct {
 	RefreshDefinitionsAfter time.Duration
 	Username                string
 	Password                string
+	// UserAgent of the wsdl and soap requests, the http client default if empty
+	UserAgent string
+	// ValidateMessages checks requests before sending and responses after receiving against the wsdl types
+	ValidateMessages bool
+	// PayloadFormat of the requests, DefaultPayloadFormat if nil. Request.PayloadFormat takes precedence
+	PayloadFormat *PayloadFormat
+	// Namespaces of the request envelope, DefaultEnvelopeNamespaces if nil
+	Namespaces *EnvelopeNamespaces
+	// Limits of the wsdl and responses read, no limits if nil
+	Limits *DecodeLimits
+	// Compression of the requests and responses, none if nil
+	Compression *Compression
+	// Transport sends the request envelopes, an HTTPTransport of the Client if nil
+	Transport Transport
+	// Idempotency attaches message IDs to the requests, none if nil
+	Idempotency *Idempotency
+	// SAMLToken placed in the security header of the requests, none if nil
+	SAMLToken *SAMLToken
+	// Encryption of the request bodies and decryption of the responses, none if nil
+	Encryption *Encryption
+	// SignatureVerifier verifies the signature of the responses, not verified if nil
+	SignatureVerifier *SignatureVerifier
 
 	once                 sync.Once
 	definitionsErr       error
 	onRequest            sync.WaitGroup
 	onDefinitionsRefresh sync.WaitGroup
 	wsdl                 string
+	faultDetails         map[xml.Name]reflect.Type
+	requestHooks         []requestHook
+	bodyHooks            []bodyHook
+
+	// set by NewClient, used by the connection options
+	dialer       *net.Dialer
+	roundTripper *http.Transport
+	proxy        *url.URL
 }
 
 // Call call's the method m with Params p
@@ -61,6 +98,11 @@ func (c *Client) Call(m string, p Params) (res *Response, err error) {
 	return c.Do(NewRequest(m, p))
 }
 
+// CallArray call's the method m with ArrayParams p, encoded in order
+func (c *Client) CallArray(m string, p ArrayParams) (res *Response, err error) {
+	return c.Do(NewArrayRequest(m, p))
+}
+
 // Call call's by struct
 func (c *Client) CallByStruct(s RequestStruct) (res *Response, err error) {
 	req, err := NewRequestByStruct(s)
@@ -99,12 +141,17 @@ func (c *Client) SetWSDL(wsdl string) {
 	c.initWsdl()
 }
 
-// Process Soap Request
-func (c *Client) Do(req *Request) (res *Response, err error) {
+// LoadDefinitions fetches and parses the wsdl, it's done by the first request
+// otherwise. Useful to inspect Definitions before calling any operation
+func (c *Client) LoadDefinitions() error {
 	c.onDefinitionsRefresh.Wait()
 	c.onRequest.Add(1)
 	defer c.onRequest.Done()
 
+	return c.loadDefinitions()
+}
+
+func (c *Client) loadDefinitions() error {
 	c.once.Do(func() {
 		c.initWsdl()
 		// 15 minute to prevent abuse.
@@ -113,8 +160,17 @@ func (c *Client) Do(req *Request) (res *Response, err error) {
 		}
 	})
 
-	if c.definitionsErr != nil {
-		return nil, c.definitionsErr
+	return c.definitionsErr
+}
+
+// Process Soap Request
+func (c *Client) Do(req *Request) (res *Response, err error) {
+	c.onDefinitionsRefresh.Wait()
+	c.onRequest.Add(1)
+	defer c.onRequest.Done()
+
+	if err := c.loadDefinitions(); err != nil {
+		return nil, err
 	}
 
 	if c.Definitions == nil {
@@ -125,6 +181,16 @@ func (c *Client) Do(req *Request) (res *Response, err error) {
 		return nil, errors.New("No Services found in wsdl definitions")
 	}
 
+	if err := c.Idempotency.assign(req); err != nil {
+		return nil, err
+	}
+
+	req, err = c.runRequestHooks(req)
+	if err != nil {
+		return nil, err
+	}
+	req = c.Idempotency.withHeader(req)
+
 	p := &process{
 		Client:     c,
 		Request:    req,
@@ -135,77 +201,145 @@ func (c *Client) Do(req *Request) (res *Response, err error) {
 		p.SoapAction = fmt.Sprintf("%s/%s", c.URL, req.Method)
 	}
 
-	p.Payload, err = xml.MarshalIndent(p, "", "    ")
+	p.Format = c.payloadFormat(req)
+	p.Payload, err = p.Format.marshalUTF8(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.ValidateMessages {
+		if err := c.validateRequest(req.Method, p.Payload); err != nil {
+			return nil, ErrorWithPayload{err, p.Payload}
+		}
+	}
+
+	if c.SAMLToken != nil {
+		p.Payload, err = c.SAMLToken.addTo(p.Payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Encryption != nil {
+		p.Payload, err = c.Encryption.EncryptEnvelope(p.Payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.Payload, err = p.Format.encode(p.Payload)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := p.doRequest(c.Definitions.Services[0].Ports[0].SoapAddresses[0].Location)
+	location, err := c.Definitions.Endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := c.Idempotency.send(p.send, location)
 	if err != nil {
 		return nil, ErrorWithPayload{err, p.Payload}
 	}
 
+	if err := c.Idempotency.done(req); err != nil {
+		return nil, ErrorWithPayload{err, p.Payload}
+	}
+
 	var soap SoapEnvelope
 	// err = xml.Unmarshal(b, &soap)
 	// error: xml: encoding "ISO-8859-1" declared but Decoder.CharsetReader is nil
 	// https://stackoverflow.com/questions/6002619/unmarshal-an-iso-8859-1-xml-input-in-go
 	// https://github.com/golang/go/issues/8937
 
+	if err := c.Limits.check(b); err != nil {
+		return nil, ErrorWithPayload{err, p.Payload}
+	}
+
+	// decryption and signatures work on the envelope in UTF-8
+	decrypt := c.Encryption != nil && c.Encryption.Key != nil
+	if decrypt || c.SignatureVerifier != nil {
+		b, err = utf8Envelope(b)
+		if err != nil {
+			return nil, ErrorWithPayload{err, p.Payload}
+		}
+	}
+
+	if decrypt {
+		b, err = c.Encryption.DecryptEnvelope(b)
+		if err != nil {
+			return nil, ErrorWithPayload{err, p.Payload}
+		}
+
+		// the limits apply to the decrypted content too
+		if err := c.Limits.check(b); err != nil {
+			return nil, ErrorWithPayload{err, p.Payload}
+		}
+	}
+
+	var signer *x509.Certificate
+	if c.SignatureVerifier != nil {
+		signer, err = c.SignatureVerifier.VerifyEnvelope(b)
+		if err != nil {
+			return nil, ErrorWithPayload{err, p.Payload}
+		}
+	}
+
 	decoder := xml.NewDecoder(bytes.NewReader(b))
 	decoder.CharsetReader = charset.NewReaderLabel
 	err = decoder.Decode(&soap)
 
 	res = &Response{
-		Body:    soap.Body.Contents,
-		Header:  soap.Header.Contents,
-		Payload: p.Payload,
+		Body:     soap.Body.Contents,
+		Header:   soap.Header.Contents,
+		Payload:  p.Payload,
+		Envelope: b,
+		Exchange: p.Exchange,
+		Sizes:    p.Sizes,
+
+		SignerCertificate: signer,
+
+		faultDetails: c.faultDetails,
+		scope:        bodyScope(b),
 	}
 	if err != nil {
 		return res, ErrorWithPayload{err, p.Payload}
 	}
 
-	return res, nil
-}
-
-type process struct {
-	Client     *Client
-	Request    *Request
-	SoapAction string
-	Payload    []byte
-}
-
-// doRequest makes new request to the server using the c.Method, c.URL and the body.
-// body is enveloped in Do method
-func (p *process) doRequest(url string) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(p.Payload))
+	res.Body, err = c.runBodyHooks(req.Method, res.Body)
 	if err != nil {
-		return nil, err
+		return res, ErrorWithPayload{err, p.Payload}
 	}
 
-	if p.Client.Username != "" && p.Client.Password != "" {
-		req.SetBasicAuth(p.Client.Username, p.Client.Password)
+	if c.ValidateMessages && res.fault() == nil {
+		if err := c.Definitions.ValidateResponse(req.Method, res.Body); err != nil {
+			return res, ErrorWithPayload{err, p.Payload}
+		}
 	}
 
-	req.ContentLength = int64(len(p.Payload))
-
-	req.Header.Add("Content-Type", "text/xml;charset=UTF-8")
-	req.Header.Add("Accept", "text/xml")
-	req.Header.Add("SOAPAction", p.SoapAction)
+	return res, nil
+}
 
-	resp, err := p.httpClient().Do(req)
-	if err != nil {
-		return nil, err
+// validateRequest checks the body of the request envelope against the wsdl types
+func (c *Client) validateRequest(m string, payload []byte) error {
+	var soap SoapEnvelope
+	decoder := xml.NewDecoder(bytes.NewReader(payload))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err := decoder.Decode(&soap); err != nil {
+		return err
 	}
-	defer resp.Body.Close()
 
-	return ioutil.ReadAll(resp.Body)
+	return c.Definitions.ValidateRequest(m, soap.Body.Contents)
 }
 
-func (p *process) httpClient() *http.Client {
-	if p.Client.HttpClient != nil {
-		return p.Client.HttpClient
-	}
-	return http.DefaultClient
+type process struct {
+	Client     *Client
+	Request    *Request
+	SoapAction string
+	Format     PayloadFormat
+	Payload    []byte
+	Sizes      TransferSizes
+	Exchange   *Exchange
 }
 
 type ErrorWithPayload struct {
@@ -213,6 +347,11 @@ type ErrorWithPayload struct {
 	Payload []byte
 }
 
+// Unwrap returns the error of the request, to be used with errors.Is and errors.As
+func (e ErrorWithPayload) Unwrap() error {
+	return e.error
+}
+
 func GetPayloadFromError(err error) []byte {
 	if err, ok := err.(ErrorWithPayload); ok {
 		return err.Payload
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
 )
 
@@ -205,19 +206,19 @@ func TestClient_Call_NonUtf8(t *testing.T) {
 	}
 }
 
-func TestProcess_doRequest(t *testing.T) {
-	c := &process{
+func TestHTTPTransport_Send(t *testing.T) {
+	c := &HTTPTransport{
 		Client: &Client{
 			HttpClient: &http.Client{},
 		},
 	}
 
-	_, err := c.doRequest("")
+	_, err := c.Send(&Message{URL: ""})
 	if err == nil {
 		t.Errorf("body is empty")
 	}
 
-	_, err = c.doRequest("://teste.")
+	_, err = c.Send(&Message{URL: "://teste."})
 	if err == nil {
 		t.Errorf("invalid WSDL")
 	}
@@ -261,3 +262,26 @@ func TestClient_CallWithAuth(t *testing.T) {
 		t.Errorf("error in soap call: %s", err)
 	}
 }
+
+// newTestServer serves the wsdl file from testdata with its soap address pointing
+// to the server itself, soap requests are answered by h
+func newTestServer(t *testing.T, wsdl string, h http.HandlerFunc) *httptest.Server {
+	data, err := ioutil.ReadFile(fmt.Sprintf("testdata/%s", wsdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write(wsdlLocation.ReplaceAll(data, []byte(fmt.Sprintf(`$1="%s/soap"`, ts.URL))))
+			return
+		}
+
+		h(w, r)
+	}))
+
+	return ts
+}
+
+var wsdlLocation = regexp.MustCompile(`(location|address)="[^"]*"`)
@@ -0,0 +1,101 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Templates holds text/template xml body templates of operations. Each template is named
+// after the operation it builds, without the file extension: GetOrder.xml builds GetOrder.
+// Templates render the operation element itself, the envelope is added when sending.
+// The xml function escapes values: <orderId>{{xml .ID}}</orderId>
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// ParseTemplateFiles parses the template files named by filenames
+func ParseTemplateFiles(filenames ...string) (*Templates, error) {
+	t, err := template.New("").Funcs(templateFuncs).ParseFiles(filenames...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTemplates(t), nil
+}
+
+// ParseTemplateGlob parses the template files matching pattern
+func ParseTemplateGlob(pattern string) (*Templates, error) {
+	t, err := template.New("").Funcs(templateFuncs).ParseGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTemplates(t), nil
+}
+
+// ParseTemplateFS parses the template files of fsys matching patterns
+func ParseTemplateFS(fsys fs.FS, patterns ...string) (*Templates, error) {
+	t, err := template.New("").Funcs(templateFuncs).ParseFS(fsys, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTemplates(t), nil
+}
+
+var templateFuncs = template.FuncMap{
+	"xml": func(v interface{}) (string, error) {
+		var b bytes.Buffer
+		err := xml.EscapeText(&b, []byte(fmt.Sprint(v)))
+		return b.String(), err
+	},
+}
+
+func newTemplates(t *template.Template) *Templates {
+	ts := &Templates{templates: map[string]*template.Template{}}
+	for _, tt := range t.Templates() {
+		if tt.Name() == "" {
+			continue
+		}
+
+		ts.templates[strings.TrimSuffix(tt.Name(), path.Ext(tt.Name()))] = tt
+	}
+
+	return ts
+}
+
+// Request renders the template of the operation with data and returns a request
+// sending it as body. The rendered xml must be well-formed
+func (ts *Templates) Request(operation string, data interface{}) (*Request, error) {
+	t, ok := ts.templates[operation]
+	if !ok {
+		return nil, fmt.Errorf("template of operation %q not found", operation)
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+
+	body := RawXML(b.String())
+	if _, err := body.tokens(); err != nil {
+		return nil, fmt.Errorf("template of operation %q: %s", operation, err)
+	}
+
+	return NewRawRequest(operation, body), nil
+}
+
+// CallTemplate renders the template of the operation m with data and sends it
+func (c *Client) CallTemplate(ts *Templates, m string, data interface{}) (*Response, error) {
+	req, err := ts.Request(m, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
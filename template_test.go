@@ -0,0 +1,96 @@
+package gosoap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplates_Request(t *testing.T) {
+	ts, err := ParseTemplateFS(os.DirFS("testdata/templates"), "*.xml")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	type item struct {
+		SKU      string
+		Quantity int
+	}
+
+	req, err := ts.Request("CreateOrder", map[string]interface{}{
+		"CustomerID": "1",
+		"Street":     "Main & 1st",
+		"City":       "<Lisbon>",
+		"Items":      []item{{"ABC-0001", 2}, {"ABC-0002", 1}},
+	})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	diffs, err := DiffXML([]byte(req.Body), []byte(`<CreateOrder xmlns="http://example.com/orders"><customerId>1</customerId>`+
+		`<address><street>Main &amp; 1st</street><city>&lt;Lisbon&gt;</city></address>`+
+		`<item><sku>ABC-0001</sku><quantity>2</quantity></item><item><sku>ABC-0002</sku><quantity>1</quantity></item>`+
+		`</CreateOrder>`))
+	if err != nil || len(diffs) > 0 {
+		t.Errorf("unexpected body %v %v:\n%s", err, diffs, req.Body)
+	}
+
+	if _, err := ts.Request("DeleteOrder", nil); err == nil {
+		t.Errorf("error expected for unknown operation")
+	}
+
+	ts, err = ParseTemplateFS(fstest.MapFS{"GetOrder.tmpl": {Data: []byte(`<GetOrder><orderId>{{.}}</orderId>`)}}, "*.tmpl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if _, err := ts.Request("GetOrder", "1"); err == nil {
+		t.Errorf("error expected for malformed xml")
+	}
+}
+
+func TestClient_CallTemplate(t *testing.T) {
+	srv := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		if action := r.Header.Get("SOAPAction"); action != "http://example.com/orders/GetOrder" {
+			t.Errorf("unexpected SOAPAction %q", action)
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		diffs, err := DiffXML(body, []byte(`<Envelope xmlns="http://schemas.xmlsoap.org/soap/envelope/"><Header><token xmlns="">t</token></Header>`+
+			`<Body><GetOrder xmlns="http://example.com/orders"><orderId>7</orderId></GetOrder></Body></Envelope>`))
+		if err != nil || len(diffs) > 0 {
+			t.Errorf("unexpected request %v %v:\n%s", err, diffs, body)
+		}
+
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<GetOrderResponse xmlns="http://example.com/orders"><orderId>7</orderId></GetOrderResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	})
+	defer srv.Close()
+
+	c, err := SoapClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.HeaderParams = HeaderParams{"token": "t"}
+
+	ts, err := ParseTemplateGlob("testdata/templates/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.CallTemplate(ts, "GetOrder", struct{ OrderID string }{"7"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	var r struct {
+		OrderID string `xml:"orderId"`
+	}
+	if err := res.Unmarshal(&r); err != nil || r.OrderID != "7" {
+		t.Errorf("unexpected response %v: %+v", err, r)
+	}
+}
@@ -0,0 +1,295 @@
+package gosoap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a request envelope and its metadata, as given to a Transport
+type Message struct {
+	// Operation called
+	Operation string
+	// URL of the endpoint in the wsdl
+	URL string
+	// Action is the SOAPAction of the operation
+	Action string
+	// Version of SOAP of the envelope, 1.1 or 1.2
+	Version string
+	// ContentType of the envelope, with its charset, and the action for SOAP 1.2
+	ContentType string
+	// Header holds the Request.HTTPHeader, never nil
+	Header http.Header
+	// Envelope of the request, not compressed
+	Envelope []byte
+
+	// Exchange and Sizes are set by the transports talking http, such as HTTPTransport
+	Exchange *Exchange
+	Sizes    *TransferSizes
+}
+
+// Transport sends request envelopes and returns the response envelopes. The Client
+// uses an HTTPTransport if its Transport is nil
+type Transport interface {
+	Send(m *Message) ([]byte, error)
+}
+
+// TransportFunc is a function used as Transport
+type TransportFunc func(m *Message) ([]byte, error)
+
+// Send calls f(m)
+func (f TransportFunc) Send(m *Message) ([]byte, error) {
+	return f(m)
+}
+
+// WithTransport sends the requests with t instead of the HTTPTransport of the client
+func WithTransport(t Transport) Option {
+	return func(c *Client) error {
+		c.Transport = t
+		return nil
+	}
+}
+
+// HTTPTransport posts the envelopes to their URL with the http settings of Client:
+// HttpClient, Username and Password, UserAgent, Compression and Limits. It's the
+// Transport of the clients made by SoapClient and NewClient, it may be wrapped to
+// observe or change the messages
+type HTTPTransport struct {
+	Client *Client
+}
+
+// Send posts the envelope of m and returns the response body, m.Exchange and m.Sizes are set
+func (t *HTTPTransport) Send(m *Message) ([]byte, error) {
+	c := t.Client
+	body, err := c.Compression.encode(m.Envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", m.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Username != "" && c.Password != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	req.ContentLength = int64(len(body))
+	sizes := &TransferSizes{Request: int64(len(m.Envelope)), RequestWire: int64(len(body))}
+
+	req.Header.Add("Content-Type", m.ContentType)
+	if m.Version == "1.2" {
+		req.Header.Add("Accept", "application/soap+xml")
+	} else {
+		req.Header.Add("Accept", "text/xml")
+		req.Header.Add("SOAPAction", m.Action)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	for k, v := range m.Header {
+		req.Header[k] = append(req.Header[k], v...)
+	}
+	if comp := c.Compression; comp != nil {
+		if comp.Request != "" {
+			req.Header.Set("Content-Encoding", strings.ToLower(comp.Request))
+		}
+		if len(comp.Accept) > 0 {
+			req.Header.Set("Accept-Encoding", strings.Join(comp.Accept, ", "))
+		}
+	}
+
+	resp, err := t.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	wire := &countingReader{r: resp.Body}
+	r, err := decodeBody(resp.Header.Get("Content-Encoding"), wire)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := c.Limits.readAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes.Response, sizes.ResponseWire = int64(len(b)), wire.n
+	if resp.Uncompressed {
+		sizes.ResponseWire = -1
+	}
+
+	m.Sizes = sizes
+	m.Exchange = &Exchange{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		Proto:          resp.Proto,
+		RequestHeader:  req.Header.Clone(),
+		RequestBody:    m.Envelope,
+		Status:         resp.Status,
+		ResponseHeader: resp.Header.Clone(),
+		ResponseBody:   b,
+	}
+
+	return b, nil
+}
+
+func (t *HTTPTransport) httpClient() *http.Client {
+	if t.Client.HttpClient != nil {
+		return t.Client.HttpClient
+	}
+	return http.DefaultClient
+}
+
+// FileTransport drops each request envelope as a file in Outbox and waits for the
+// response envelope with the same name in Inbox, for batch integrations. The
+// metadata of the message is written beside the envelope, with the .json extension.
+// Responses must be complete once visible in Inbox, e.g. renamed once written
+type FileTransport struct {
+	Outbox string
+	Inbox  string
+	// PollInterval between checks of the Inbox, 100ms if zero
+	PollInterval time.Duration
+	// Timeout waiting for the response, 30s if zero
+	Timeout time.Duration
+}
+
+// fileMetadata is the content of the .json file
+type fileMetadata struct {
+	Operation   string      `json:"operation"`
+	URL         string      `json:"url"`
+	Action      string      `json:"action"`
+	Version     string      `json:"version"`
+	ContentType string      `json:"contentType"`
+	Header      http.Header `json:"header,omitempty"`
+}
+
+// Send writes the envelope and its metadata to Outbox and returns the response read from Inbox.
+// Files are written under a temporary name first, so they're complete once visible
+func (t *FileTransport) Send(m *Message) ([]byte, error) {
+	id, err := messageFileName()
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := json.MarshalIndent(fileMetadata{
+		Operation:   m.Operation,
+		URL:         m.URL,
+		Action:      m.Action,
+		Version:     m.Version,
+		ContentType: m.ContentType,
+		Header:      m.Header,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(filepath.Join(t.Outbox, id+".json"), meta); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(filepath.Join(t.Outbox, id+".xml"), m.Envelope); err != nil {
+		return nil, err
+	}
+
+	interval, timeout := t.PollInterval, t.Timeout
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	res := filepath.Join(t.Inbox, id+".xml")
+	deadline := time.Now().Add(timeout)
+	for {
+		b, err := ioutil.ReadFile(res)
+		if err == nil {
+			return b, os.Remove(res)
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("no response %s after %s", res, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// messageFileName returns a unique name for the files of a message
+func messageFileName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(b)), nil
+}
+
+func writeFileAtomic(name string, b []byte) error {
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
+
+// send sends the request with the Transport of the Client
+func (p *process) send(url string) ([]byte, error) {
+	t := p.Client.Transport
+	if t == nil {
+		t = &HTTPTransport{Client: p.Client}
+	}
+
+	m := &Message{
+		Operation:   p.Request.Method,
+		URL:         url,
+		Action:      p.SoapAction,
+		Version:     p.Client.Definitions.soapVersion(),
+		ContentType: "text/xml;charset=" + p.Format.charsetName(),
+		Header:      http.Header{},
+		Envelope:    p.Payload,
+	}
+	if m.Version == "1.2" {
+		m.ContentType = fmt.Sprintf("application/soap+xml;charset=%s;action=%q", p.Format.charsetName(), m.Action)
+	}
+	for k, v := range p.Request.HTTPHeader {
+		m.Header[k] = append([]string(nil), v...)
+	}
+
+	b, err := t.Send(m)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = p.Client.Limits.readAll(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	p.Exchange = m.Exchange
+	p.Sizes = TransferSizes{
+		Request:      int64(len(p.Payload)),
+		RequestWire:  int64(len(p.Payload)),
+		Response:     int64(len(b)),
+		ResponseWire: int64(len(b)),
+	}
+	if m.Sizes != nil {
+		p.Sizes = *m.Sizes
+	}
+
+	return b, nil
+}
@@ -0,0 +1,153 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTransport(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request must not be sent over http")
+	})
+	defer ts.Close()
+
+	outbox, inbox := t.TempDir(), t.TempDir()
+
+	// the batch peer answers each envelope dropped in the outbox
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+
+			files, _ := filepath.Glob(filepath.Join(outbox, "*.xml"))
+			for _, f := range files {
+				var meta struct {
+					Operation, Action, ContentType string
+					Header                         map[string][]string
+				}
+				b, _ := ioutil.ReadFile(strings.TrimSuffix(f, ".xml") + ".json")
+				if err := json.Unmarshal(b, &meta); err != nil || meta.Operation != "GetOrder" || meta.Action != "http://example.com/orders/GetOrder" ||
+					meta.ContentType != "text/xml;charset=UTF-8" || meta.Header["X-Batch"][0] != "42" {
+					t.Errorf("unexpected metadata %s", b)
+				}
+
+				if b, _ := ioutil.ReadFile(f); !bytes.Contains(b, []byte("<orderId>1</orderId>")) {
+					t.Errorf("unexpected envelope %s", b)
+				}
+
+				writeFileAtomic(filepath.Join(inbox, filepath.Base(f)), []byte(fmt.Sprintf(getOrderResponse, "1", "")))
+				os.Remove(f)
+				os.Remove(strings.TrimSuffix(f, ".xml") + ".json")
+			}
+		}
+	}()
+
+	c, err := NewClient(ts.URL+"?wsdl", WithTransport(&FileTransport{Outbox: outbox, Inbox: inbox, PollInterval: time.Millisecond, Timeout: 5 * time.Second}))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	req := NewRequest("GetOrder", Params{"orderId": "1"})
+	req.HTTPHeader = http.Header{"X-Batch": {"42"}}
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if !bytes.Contains(res.Body, []byte("<status>NEW</status>")) {
+		t.Errorf("unexpected body %s", res.Body)
+	}
+
+	if left, _ := filepath.Glob(filepath.Join(inbox, "*")); len(left) > 0 {
+		t.Errorf("response files must be removed, got %v", left)
+	}
+
+	c.Transport = &FileTransport{Outbox: t.TempDir(), Inbox: t.TempDir(), PollInterval: time.Millisecond, Timeout: 20 * time.Millisecond}
+	if _, err := c.Call("GetOrder", Params{"orderId": "1"}); err == nil || !strings.Contains(err.Error(), "no response") {
+		t.Errorf("timeout error expected, got %v", err)
+	}
+}
+
+func TestTransportFunc(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", nil)
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL+"?wsdl", WithLimits(DecodeLimits{MaxResponseSize: 1 << 16}))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	c.Transport = TransportFunc(func(m *Message) ([]byte, error) {
+		if m.URL != ts.URL+"/soap" {
+			t.Errorf("unexpected url %s", m.URL)
+		}
+		return []byte(fmt.Sprintf(getOrderResponse, "1", "")), nil
+	})
+
+	res, err := c.Call("GetOrder", Params{"orderId": "1"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if res.Sizes.Request != int64(len(res.Payload)) || res.Sizes.Response == 0 || res.Exchange != nil {
+		t.Errorf("unexpected response %+v", res)
+	}
+
+	c.Transport = TransportFunc(func(m *Message) ([]byte, error) {
+		return bytes.Repeat([]byte(" "), 1<<17), nil
+	})
+
+	var le *LimitError
+	if _, err := c.Call("GetOrder", Params{"orderId": "1"}); !errors.As(err, &le) {
+		t.Errorf("LimitError expected, got %v", err)
+	}
+}
+
+func TestHTTPTransport_Wrap(t *testing.T) {
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Trace") != "1" {
+			t.Errorf("header of the wrapper expected")
+		}
+		fmt.Fprintf(w, getOrderResponse, "1", "")
+	})
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL+"?wsdl", WithCompression("gzip"))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	next := c.Transport
+	if _, ok := next.(*HTTPTransport); !ok {
+		t.Fatalf("HTTPTransport must be the default, got %T", next)
+	}
+
+	var sent []string
+	c.Transport = TransportFunc(func(m *Message) ([]byte, error) {
+		sent = append(sent, m.Operation)
+		m.Header.Set("X-Trace", "1")
+		return next.Send(m)
+	})
+
+	res, err := c.Call("GetOrder", Params{"orderId": "1"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if len(sent) != 1 || res.Exchange == nil || res.Exchange.Status != "200 OK" || res.Sizes.RequestWire == res.Sizes.Request {
+		t.Errorf("unexpected response %v %+v", sent, res.Sizes)
+	}
+}
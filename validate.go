@@ -0,0 +1,460 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation is a schema constraint not met by an element of a message
+type Violation struct {
+	Path    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// ValidationError is returned when a message doesn't match the wsdl types
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+
+	return fmt.Sprintf("validation failed: %s", strings.Join(s, "; "))
+}
+
+// ValidateRequest checks the body of a request to the operation against the wsdl types.
+// A *ValidationError lists every violation found
+func (wsdl *wsdlDefinitions) ValidateRequest(operation string, body []byte) error {
+	s, err := wsdl.InputSchema(operation)
+	if err != nil {
+		return err
+	}
+
+	return validateBody(s, body)
+}
+
+// ValidateResponse checks the body of a response of the operation against the wsdl types.
+// A *ValidationError lists every violation found
+func (wsdl *wsdlDefinitions) ValidateResponse(operation string, body []byte) error {
+	s, err := wsdl.OutputSchema(operation)
+	if err != nil {
+		return err
+	}
+
+	return validateBody(s, body)
+}
+
+// node is an element of a validated message
+type node struct {
+	name     string
+	text     string
+	nil      bool
+	children []*node
+}
+
+func validateBody(s *SchemaElement, body []byte) error {
+	root, err := parseNode(body)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{}
+	if root == nil {
+		v.add("/"+s.Name, "required element missing")
+	} else if root.name != s.Name {
+		v.add("/"+root.name, fmt.Sprintf("element %s expected", s.Name))
+	} else {
+		v.element(root, s, "/"+s.Name)
+	}
+
+	if len(v.violations) > 0 {
+		return &ValidationError{Violations: v.violations}
+	}
+
+	return nil
+}
+
+// parseNode returns the first element of the xml fragment b
+func parseNode(b []byte) (*node, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	var stack []*node
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local}
+			for _, a := range t.Attr {
+				if a.Name.Local == "nil" && a.Name.Space == "http://www.w3.org/2001/XMLSchema-instance" {
+					n.nil = a.Value == "true" || a.Value == "1"
+				}
+			}
+
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return n, nil
+			}
+		}
+	}
+}
+
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(path, msg string) {
+	v.violations = append(v.violations, Violation{Path: path, Message: msg})
+}
+
+func (v *validator) element(n *node, s *SchemaElement, path string) {
+	if n.nil {
+		if !s.Nillable {
+			v.add(path, "element is not nillable")
+		}
+		return
+	}
+
+	if len(s.Children) == 0 {
+		if len(n.children) > 0 {
+			v.add(path, "simple element must not have child elements")
+			return
+		}
+
+		v.value(strings.TrimSpace(n.text), s, path)
+		return
+	}
+
+	counts := map[string]int{}
+	last := -1
+	for _, c := range n.children {
+		i := childIndex(s, c.name)
+		if i < 0 {
+			v.add(path+"/"+c.name, "element not declared in schema")
+			continue
+		}
+
+		if i < last {
+			v.add(path+"/"+c.name, "element out of sequence order")
+		}
+		last = i
+
+		cs := s.Children[i]
+		counts[c.name]++
+		p := path + "/" + c.name
+		if cs.IsRepeated() {
+			p = fmt.Sprintf("%s[%d]", p, counts[c.name])
+		}
+
+		v.element(c, cs, p)
+	}
+
+	for _, cs := range s.Children {
+		n := counts[cs.Name]
+		switch {
+		case n == 0 && cs.MinOccurs > 0:
+			v.add(path+"/"+cs.Name, "required element missing")
+		case n < cs.MinOccurs:
+			v.add(path+"/"+cs.Name, fmt.Sprintf("occurs %d times, minimum is %d", n, cs.MinOccurs))
+		case cs.MaxOccurs != Unbounded && n > cs.MaxOccurs:
+			v.add(path+"/"+cs.Name, fmt.Sprintf("occurs %d times, maximum is %d", n, cs.MaxOccurs))
+		}
+	}
+}
+
+func childIndex(s *SchemaElement, name string) int {
+	for i, c := range s.Children {
+		if c.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// value checks the text of a simple element against its type and facets
+func (v *validator) value(text string, s *SchemaElement, path string) {
+	if err := checkType(localName(s.Type), text); err != nil {
+		v.add(path, fmt.Sprintf("%q is not a valid %s: %s", text, s.Type, err))
+		return
+	}
+
+	r := s.Restriction
+	if r == nil {
+		return
+	}
+
+	if len(r.Enumeration) > 0 {
+		found := false
+		for _, e := range r.Enumeration {
+			if e == text {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			v.add(path, fmt.Sprintf("%q is not one of %s", text, strings.Join(r.Enumeration, ", ")))
+		}
+	}
+
+	patterns := r.patterns
+	if patterns == nil {
+		patterns = compilePatterns(r.Patterns)
+	}
+	for i, re := range patterns {
+		// patterns without a regexp equivalent are reported by Lint, not checked
+		if re != nil && !re.MatchString(text) {
+			v.add(path, fmt.Sprintf("%q does not match pattern %s", text, r.Patterns[i]))
+		}
+	}
+
+	if r.MinInclusive != "" || r.MaxInclusive != "" {
+		n, ok := new(big.Float).SetString(text)
+		if min, minOk := new(big.Float).SetString(r.MinInclusive); ok && minOk && n.Cmp(min) < 0 {
+			v.add(path, fmt.Sprintf("%s is less than %s", text, r.MinInclusive))
+		}
+		if max, maxOk := new(big.Float).SetString(r.MaxInclusive); ok && maxOk && n.Cmp(max) > 0 {
+			v.add(path, fmt.Sprintf("%s is greater than %s", text, r.MaxInclusive))
+		}
+	}
+
+	length := utf8.RuneCountInString(text)
+	if l, err := strconv.Atoi(r.Length); err == nil && length != l {
+		v.add(path, fmt.Sprintf("length is %d, must be %d", length, l))
+	}
+	if l, err := strconv.Atoi(r.MinLength); err == nil && length < l {
+		v.add(path, fmt.Sprintf("length is %d, minimum is %d", length, l))
+	}
+	if l, err := strconv.Atoi(r.MaxLength); err == nil && length > l {
+		v.add(path, fmt.Sprintf("length is %d, maximum is %d", length, l))
+	}
+}
+
+// pattern returns the regexp of the xml schema pattern p, compiled once for the definitions.
+// It's nil when p has no regexp equivalent
+func (wsdl *wsdlDefinitions) pattern(p string) *regexp.Regexp {
+	if re, ok := wsdl.patterns.Load(p); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re, _ := compilePattern(p)
+	wsdl.patterns.Store(p, re)
+	return re
+}
+
+// compilePatterns compiles the patterns of a restriction, nil for the ones compilePattern rejects
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i], _ = compilePattern(p)
+	}
+
+	return res
+}
+
+// compilePattern translates the xml schema pattern p to an anchored regexp. The \i and \c
+// classes and the IsBasicLatin and IsLatin-1Supplement blocks are translated, class
+// subtractions and other blocks have no equivalent and return an error
+func compilePattern(p string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	class := false
+	rs := []rune(p)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\' && i+1 < len(rs):
+			i++
+			e := rs[i]
+			switch e {
+			case 'i', 'c':
+				if class {
+					b.WriteString(xsdNameClasses[e])
+				} else {
+					b.WriteString("[" + xsdNameClasses[e] + "]")
+				}
+			case 'I', 'C':
+				if class {
+					return nil, fmt.Errorf("\\%c can't be used in a character class", e)
+				}
+				b.WriteString("[^" + xsdNameClasses[e] + "]")
+			case 'p', 'P':
+				end := i + 1
+				for end < len(rs) && rs[end] != '}' {
+					end++
+				}
+				if i+1 >= len(rs) || rs[i+1] != '{' || end == len(rs) {
+					return nil, fmt.Errorf("invalid escape \\%c", e)
+				}
+				name := string(rs[i+2 : end])
+				i = end
+				if !strings.HasPrefix(name, "Is") {
+					b.WriteString("\\" + string(e) + "{" + name + "}")
+					break
+				}
+
+				block, ok := xsdBlocks[name]
+				switch {
+				case !ok:
+					return nil, fmt.Errorf("block %s isn't supported", name)
+				case class && e == 'P':
+					return nil, fmt.Errorf("\\P{%s} can't be used in a character class", name)
+				case class:
+					b.WriteString(block)
+				case e == 'P':
+					b.WriteString("[^" + block + "]")
+				default:
+					b.WriteString("[" + block + "]")
+				}
+			default:
+				b.WriteRune('\\')
+				b.WriteRune(e)
+			}
+		case class && r == '-' && i+1 < len(rs) && rs[i+1] == '[':
+			return nil, fmt.Errorf("character class subtraction isn't supported")
+		case r == '[' && !class:
+			class = true
+			b.WriteRune(r)
+		case r == ']' && class:
+			class = false
+			b.WriteRune(r)
+		case (r == '^' || r == '$') && !class:
+			// anchors are literal characters in xml schema patterns
+			b.WriteString("\\" + string(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	// xml schema patterns are implicitly anchored
+	return regexp.Compile("^(?:" + b.String() + ")$")
+}
+
+var (
+	// xsdNameClasses are the sets of the \i and \c classes, and of their \I and \C complements
+	xsdNameClasses = map[rune]string{
+		'i': `_:\pL`,
+		'I': `_:\pL`,
+		'c': `\-.0-9_:\pL\pM\pN`,
+		'C': `\-.0-9_:\pL\pM\pN`,
+	}
+
+	// xsdBlocks are the unicode blocks translated by compilePattern
+	xsdBlocks = map[string]string{
+		"IsBasicLatin":         `\x{0}-\x{7F}`,
+		"IsLatin-1Supplement":  `\x{80}-\x{FF}`,
+		"IsLatinExtended-A":    `\x{100}-\x{17F}`,
+		"IsGreekandCoptic":     `\x{370}-\x{3FF}`,
+		"IsCyrillic":           `\x{400}-\x{4FF}`,
+		"IsGeneralPunctuation": `\x{2000}-\x{206F}`,
+	}
+
+	decimalPattern = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)$`)
+
+	integerRanges = map[string][2]string{
+		"long":               {"-9223372036854775808", "9223372036854775807"},
+		"int":                {"-2147483648", "2147483647"},
+		"short":              {"-32768", "32767"},
+		"byte":               {"-128", "127"},
+		"unsignedLong":       {"0", "18446744073709551615"},
+		"unsignedInt":        {"0", "4294967295"},
+		"unsignedShort":      {"0", "65535"},
+		"unsignedByte":       {"0", "255"},
+		"nonNegativeInteger": {"0", ""},
+		"positiveInteger":    {"1", ""},
+		"nonPositiveInteger": {"", "0"},
+		"negativeInteger":    {"", "-1"},
+		"integer":            {"", ""},
+	}
+
+	timeLayouts = map[string][]string{
+		"date":     {"2006-01-02", "2006-01-02Z07:00"},
+		"dateTime": {"2006-01-02T15:04:05", "2006-01-02T15:04:05Z07:00"},
+		"time":     {"15:04:05", "15:04:05Z07:00"},
+	}
+)
+
+// checkType checks v is a lexical value of the builtin type t, unknown types accept any value
+func checkType(t, v string) error {
+	if r, ok := integerRanges[t]; ok {
+		n, ok := new(big.Int).SetString(strings.TrimPrefix(v, "+"), 10)
+		if !ok {
+			return fmt.Errorf("not an integer")
+		}
+
+		if min, ok := new(big.Int).SetString(r[0], 10); ok && n.Cmp(min) < 0 {
+			return fmt.Errorf("out of range")
+		}
+		if max, ok := new(big.Int).SetString(r[1], 10); ok && n.Cmp(max) > 0 {
+			return fmt.Errorf("out of range")
+		}
+
+		return nil
+	}
+
+	if layouts, ok := timeLayouts[t]; ok {
+		// fractional seconds are accepted by time.Parse even if absent from the layout
+		for _, l := range layouts {
+			if _, err := time.Parse(l, v); err == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("invalid format")
+	}
+
+	switch t {
+	case "decimal":
+		if !decimalPattern.MatchString(v) {
+			return fmt.Errorf("not a decimal")
+		}
+	case "float", "double":
+		switch v {
+		case "INF", "-INF", "NaN":
+			return nil
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("not a number")
+		}
+	case "boolean":
+		switch v {
+		case "true", "false", "1", "0":
+		default:
+			return fmt.Errorf("not a boolean")
+		}
+	}
+
+	return nil
+}
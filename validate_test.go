@@ -0,0 +1,208 @@
+package gosoap
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDefinitions_ValidateRequest(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	valid := `<CreateOrder xmlns="http://example.com/orders">
+		<customerId>42</customerId>
+		<address><street>Main</street><city>Lisbon</city></address>
+		<item><sku>ABC-0001</sku><quantity>2</quantity><price>1.50</price></item>
+		<express>true</express>
+	</CreateOrder>`
+	if err := d.ValidateRequest("CreateOrder", []byte(valid)); err != nil {
+		t.Errorf("error not expected: %s", err)
+	}
+
+	invalid := `<CreateOrder xmlns="http://example.com/orders">
+		<address><city>Lisbon</city><street>Main</street></address>
+		<item><sku>abc</sku><quantity>101</quantity></item>
+		<item><sku>ABC-0002</sku><quantity>x</quantity><price>1,5</price></item>
+		<express>yes</express>
+		<coupon>FREE</coupon>
+	</CreateOrder>`
+	err := d.ValidateRequest("CreateOrder", []byte(invalid))
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("*ValidationError expected, got %v", err)
+	}
+
+	want := []string{
+		`/CreateOrder/address/street: element out of sequence order`,
+		`/CreateOrder/item[1]/sku: "abc" does not match pattern [A-Z]{3}-[0-9]{4}`,
+		`/CreateOrder/item[1]/quantity: 101 is greater than 100`,
+		`/CreateOrder/item[2]/quantity: "x" is not a valid xs:int: not an integer`,
+		`/CreateOrder/item[2]/price: "1,5" is not a valid xs:decimal: not a decimal`,
+		`/CreateOrder/express: "yes" is not a valid xs:boolean: not a boolean`,
+		`/CreateOrder/coupon: element not declared in schema`,
+		`/CreateOrder/customerId: required element missing`,
+	}
+	if len(verr.Violations) != len(want) {
+		t.Fatalf("got violations %v, want %v", verr.Violations, want)
+	}
+	for i, v := range verr.Violations {
+		if v.String() != want[i] {
+			t.Errorf("violation %d: got %q, want %q", i, v, want[i])
+		}
+	}
+
+	err = d.ValidateResponse("GetOrder", []byte(`<GetOrderResponse><orderId>1</orderId><status>LOST</status>`+
+		`<address><street>Main</street><city>Lisbon</city></address></GetOrderResponse>`))
+	if err == nil || err.Error() != `validation failed: /GetOrderResponse/status: "LOST" is not one of NEW, SHIPPED, CANCELLED` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_ValidateMessages(t *testing.T) {
+	requests := 0
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<GetOrderResponse xmlns="http://example.com/orders"><orderId>7</orderId></GetOrderResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ValidateMessages = true
+
+	if _, err := c.Call("GetOrder", Params{"id": "7"}); err == nil || requests != 0 {
+		t.Errorf("invalid request must not be sent, error: %v", err)
+	}
+
+	res, err := c.Call("GetOrder", Params{"orderId": "7"})
+	var verr *ValidationError
+	if res == nil || !errors.As(err, &verr) || len(verr.Violations) != 2 {
+		t.Errorf("response must fail validation, got %v", err)
+	}
+}
+
+func TestClient_ValidateParamsOrder(t *testing.T) {
+	requests := 0
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<CreateOrderResponse xmlns="http://example.com/orders"><orderId>A1</orderId><status>NEW</status><total>3</total></CreateOrderResponse>`+
+			`</soap:Body></soap:Envelope>`)
+	})
+	defer ts.Close()
+
+	c, err := SoapClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ValidateMessages = true
+
+	// map params are encoded in the schema sequence order, whatever the map iteration order
+	for i := 0; i < 20; i++ {
+		_, err := c.Call("CreateOrder", Params{
+			"note":       "leave at the door",
+			"express":    "true",
+			"item":       Params{"price": "1.50", "quantity": "2", "sku": "ABC-0001"},
+			"address":    Params{"city": "Lisbon", "street": "Main"},
+			"customerId": "42",
+		})
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+	}
+
+	if requests != 20 {
+		t.Errorf("got %d requests, want 20", requests)
+	}
+}
+
+func TestValidateBody_Patterns(t *testing.T) {
+	s := &SchemaElement{Name: "code", Type: "xs:string", Restriction: &SchemaRestriction{
+		Patterns: []string{"[A-Z]+|[0-9]+", "[A-Z0-9]{2}"},
+	}}
+
+	for text, valid := range map[string]bool{"AB": true, "12": true, "A1": false, "ABC": false} {
+		err := validateBody(s, []byte("<code>"+text+"</code>"))
+		if valid != (err == nil) {
+			t.Errorf("%s: unexpected error %v", text, err)
+		}
+	}
+
+	// xml schema classes and blocks are translated
+	s.Restriction.Patterns = []string{`\i\c*`, `\p{IsBasicLatin}+`}
+	for text, valid := range map[string]bool{"a-1": true, "Ab": true, "1a": false, "aé": false} {
+		err := validateBody(s, []byte("<code>"+text+"</code>"))
+		if valid != (err == nil) {
+			t.Errorf("%s: unexpected error %v", text, err)
+		}
+	}
+
+	// patterns without a regexp equivalent are not checked
+	s.Restriction.Patterns = []string{`[a-z-[aeiou]]+`, `[A-Z]`}
+	err := validateBody(s, []byte("<code>a</code>"))
+	if err == nil || strings.Contains(err.Error(), "aeiou") || !strings.Contains(err.Error(), `does not match pattern [A-Z]`) {
+		t.Errorf("only the compiled pattern must be checked, got %v", err)
+	}
+}
+
+func TestDefinitions_PatternsCompiledOnce(t *testing.T) {
+	d := loadTestDefinitions(t, "orders.wsdl")
+
+	sku := func() *SchemaRestriction {
+		s, err := d.InputSchema("CreateOrder")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Child("item").Child("sku").Restriction
+	}
+
+	a, b := sku(), sku()
+	if len(a.patterns) != 1 || a.patterns[0] == nil || a.patterns[0] != b.patterns[0] {
+		t.Errorf("pattern compiled once expected, got %v and %v", a.patterns, b.patterns)
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{pattern: `[\i-]+`, match: []string{"a-b", "é:"}, noMatch: []string{"a1"}},
+		{pattern: `\I\C`, match: []string{"1 "}, noMatch: []string{"a1"}},
+		{pattern: `\P{IsBasicLatin}`, match: []string{"é"}, noMatch: []string{"e"}},
+		{pattern: `\p{Lu}\d`, match: []string{"É1"}, noMatch: []string{"é1"}},
+		{pattern: `^a$`, match: []string{"^a$"}, noMatch: []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		re, err := compilePattern(tt.pattern)
+		if err != nil {
+			t.Errorf("%s: error not expected: %s", tt.pattern, err)
+			continue
+		}
+		for _, m := range tt.match {
+			if !re.MatchString(m) {
+				t.Errorf("%s must match %q", tt.pattern, m)
+			}
+		}
+		for _, m := range tt.noMatch {
+			if re.MatchString(m) {
+				t.Errorf("%s must not match %q", tt.pattern, m)
+			}
+		}
+	}
+
+	for _, p := range []string{`[a-z-[aeiou]]`, `\p{IsArabic}`, `[\I]`, `(`} {
+		if _, err := compilePattern(p); err == nil {
+			t.Errorf("%s: error expected", p)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package gosoap
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	// hashes of the digest and signature algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	excC14NAlgorithm   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	c14nAlgorithm      = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	excC14NNamespace   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	x509v3ValueType    = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-x509-token-profile-1.0#X509v3"
+	x509SKIValueType   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-x509-token-profile-1.0#X509SubjectKeyIdentifier"
+	defaultClockSkew   = 5 * time.Minute
+	envelopedSignature = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+)
+
+var (
+	digestAlgorithms = map[string]crypto.Hash{
+		"http://www.w3.org/2000/09/xmldsig#sha1":  crypto.SHA1,
+		"http://www.w3.org/2001/04/xmlenc#sha256": crypto.SHA256,
+		"http://www.w3.org/2001/04/xmlenc#sha512": crypto.SHA512,
+	}
+
+	signatureAlgorithms = map[string]crypto.Hash{
+		"http://www.w3.org/2000/09/xmldsig#rsa-sha1":        crypto.SHA1,
+		"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256": crypto.SHA256,
+		"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512": crypto.SHA512,
+	}
+)
+
+// SignatureVerifier checks the ds:Signature of the WS-Security header of the responses:
+// the signer certificate must be trusted, the soap:Body and the wsu:Timestamp must be
+// signed, the digests of the references and the signature must match, and the timestamp
+// must be current. Responses encrypted after being signed are verified once decrypted
+type SignatureVerifier struct {
+	// Trusted certificates, the signer must be one of them or be issued by one of them
+	Trusted []*x509.Certificate
+	// ClockSkew allowed when checking the timestamp, 5 minutes if zero
+	ClockSkew time.Duration
+	// RequireTimestamp fails the responses without wsu:Timestamp
+	RequireTimestamp bool
+
+	// now returns the current time, time.Now if nil
+	now func() time.Time
+}
+
+// WithSignatureVerifier verifies the signature of the responses as configured by v
+func WithSignatureVerifier(v SignatureVerifier) Option {
+	return func(c *Client) error {
+		if len(v.Trusted) == 0 {
+			return fmt.Errorf("trusted certificates are required to verify signatures")
+		}
+
+		c.SignatureVerifier = &v
+		return nil
+	}
+}
+
+// SignatureError is returned when the signature of a response can't be verified
+type SignatureError struct {
+	Reason string
+}
+
+func (e *SignatureError) Error() string {
+	return "signature verification failed: " + e.Reason
+}
+
+func signatureError(format string, a ...interface{}) error {
+	return &SignatureError{Reason: fmt.Sprintf(format, a...)}
+}
+
+type wsseSecurity struct {
+	Timestamp *struct {
+		ID      string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Id,attr"`
+		Created string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Created"`
+		Expires string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Expires"`
+	} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Timestamp"`
+	Tokens []struct {
+		ID        string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Id,attr"`
+		ValueType string `xml:"ValueType,attr"`
+		Value     string `xml:",chardata"`
+	} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd BinarySecurityToken"`
+	Signature *dsSignature `xml:"http://www.w3.org/2000/09/xmldsig# Signature"`
+}
+
+type dsSignature struct {
+	SignedInfo struct {
+		CanonicalizationMethod dsTransform `xml:"http://www.w3.org/2000/09/xmldsig# CanonicalizationMethod"`
+		SignatureMethod        struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# SignatureMethod"`
+		References []struct {
+			URI          string        `xml:"URI,attr"`
+			Transforms   []dsTransform `xml:"http://www.w3.org/2000/09/xmldsig# Transforms>Transform"`
+			DigestMethod struct {
+				Algorithm string `xml:"Algorithm,attr"`
+			} `xml:"http://www.w3.org/2000/09/xmldsig# DigestMethod"`
+			DigestValue string `xml:"http://www.w3.org/2000/09/xmldsig# DigestValue"`
+		} `xml:"http://www.w3.org/2000/09/xmldsig# Reference"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# SignedInfo"`
+	SignatureValue string `xml:"http://www.w3.org/2000/09/xmldsig# SignatureValue"`
+	KeyInfo        struct {
+		TokenReference struct {
+			Reference struct {
+				URI string `xml:"URI,attr"`
+			} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Reference"`
+			KeyIdentifier struct {
+				ValueType string `xml:"ValueType,attr"`
+				Value     string `xml:",chardata"`
+			} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd KeyIdentifier"`
+			IssuerSerial dsIssuerSerial `xml:"http://www.w3.org/2000/09/xmldsig# X509Data>X509IssuerSerial"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd SecurityTokenReference"`
+		Certificate string `xml:"http://www.w3.org/2000/09/xmldsig# X509Data>X509Certificate"`
+	} `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+}
+
+type dsTransform struct {
+	Algorithm           string `xml:"Algorithm,attr"`
+	InclusiveNamespaces struct {
+		PrefixList string `xml:"PrefixList,attr"`
+	} `xml:"http://www.w3.org/2001/10/xml-exc-c14n# InclusiveNamespaces"`
+}
+
+type dsIssuerSerial struct {
+	IssuerName   string `xml:"http://www.w3.org/2000/09/xmldsig# X509IssuerName"`
+	SerialNumber string `xml:"http://www.w3.org/2000/09/xmldsig# X509SerialNumber"`
+}
+
+// signedEnvelope holds what the verification needs to know about the elements of an envelope
+type signedEnvelope struct {
+	// ids counts the elements with each Id
+	ids map[string]int
+	// bodyID and timestampID are the Id of soap:Body and of the wsu:Timestamp of the security header
+	bodyID, timestampID string
+	// signedInfo is the offset of the ds:SignedInfo of the signature of the security
+	// header, -1 if there's none
+	signedInfo int
+	// signatures, timestamps and signedInfos count the signatures and timestamps of the
+	// security header, and the SignedInfo elements of its signatures
+	signatures, timestamps, signedInfos int
+}
+
+// VerifyEnvelope verifies the signature of the envelope b and returns the signer certificate
+func (v *SignatureVerifier) VerifyEnvelope(b []byte) (*x509.Certificate, error) {
+	l, err := parseEnvelope(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.security.start < 0 {
+		return nil, signatureError("no security header")
+	}
+
+	var sec wsseSecurity
+	if err := decodeElementAt(b, l.security.start, &sec); err != nil {
+		return nil, err
+	}
+
+	if sec.Signature == nil {
+		return nil, signatureError("no signature in the security header")
+	}
+
+	env, err := scanSignedEnvelope(b, l.security)
+	if err != nil {
+		return nil, err
+	}
+
+	// repeated elements would be decoded into the same fields, the last one winning
+	switch {
+	case env.signatures > 1:
+		return nil, signatureError("more than one signature in the security header")
+	case env.timestamps > 1:
+		return nil, signatureError("more than one timestamp in the security header")
+	case env.signedInfos != 1:
+		return nil, signatureError("the header signature must have one SignedInfo")
+	}
+
+	if err := v.checkTimestamp(&sec); err != nil {
+		return nil, err
+	}
+
+	cert, err := v.signer(&sec)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := sec.Signature
+	signed := map[string]bool{}
+	for _, r := range sig.SignedInfo.References {
+		if !strings.HasPrefix(r.URI, "#") || len(r.URI) == 1 {
+			return nil, signatureError("unsupported reference %q", r.URI)
+		}
+
+		id := r.URI[1:]
+		switch env.ids[id] {
+		case 0:
+			return nil, signatureError("referenced element %q not found", id)
+		case 1:
+		default:
+			return nil, signatureError("more than one element with Id %q", id)
+		}
+
+		c14n := dsTransform{Algorithm: c14nAlgorithm}
+		for _, t := range r.Transforms {
+			if t.Algorithm == envelopedSignature {
+				return nil, signatureError("unsupported transform %q", t.Algorithm)
+			}
+			c14n = t
+		}
+
+		canonical, err := canonicalizeElement(b, c14n, func(s xml.StartElement, scope map[string]string, _ int) bool {
+			return elementID(s, scope) == id
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		digest, err := digestOf(r.DigestMethod.Algorithm, canonical)
+		if err != nil {
+			return nil, err
+		}
+
+		want, err := base64.StdEncoding.DecodeString(strings.TrimSpace(r.DigestValue))
+		if err != nil || subtle.ConstantTimeCompare(digest, want) != 1 {
+			return nil, signatureError("digest of %q doesn't match", id)
+		}
+
+		signed[id] = true
+	}
+
+	if env.bodyID == "" || !signed[env.bodyID] {
+		return nil, signatureError("body is not signed")
+	}
+	if sec.Timestamp != nil && (env.timestampID == "" || !signed[env.timestampID]) {
+		return nil, signatureError("timestamp is not signed")
+	}
+
+	// the SignedInfo checked is the one decoded from the security header
+	canonical, err := canonicalizeElement(b, sig.SignedInfo.CanonicalizationMethod, func(_ xml.StartElement, _ map[string]string, offset int) bool {
+		return offset == env.signedInfo
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hash, ok := signatureAlgorithms[sig.SignedInfo.SignatureMethod.Algorithm]
+	if !ok {
+		return nil, signatureError("unsupported signature algorithm %q", sig.SignedInfo.SignatureMethod.Algorithm)
+	}
+
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, signatureError("signer certificate must have an RSA key")
+	}
+
+	value, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(sig.SignatureValue), ""))
+	if err != nil {
+		return nil, signatureError("invalid SignatureValue: %s", err)
+	}
+
+	h := hash.New()
+	h.Write(canonical)
+	if err := rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), value); err != nil {
+		return nil, signatureError("signature doesn't match")
+	}
+
+	return cert, nil
+}
+
+func (v *SignatureVerifier) currentTime() time.Time {
+	if v.now != nil {
+		return v.now()
+	}
+
+	return time.Now()
+}
+
+// checkTimestamp checks the current time is between Created and Expires, give or take the clock skew
+func (v *SignatureVerifier) checkTimestamp(sec *wsseSecurity) error {
+	ts := sec.Timestamp
+	if ts == nil {
+		if v.RequireTimestamp {
+			return signatureError("no timestamp in the security header")
+		}
+		return nil
+	}
+
+	skew := v.ClockSkew
+	if skew == 0 {
+		skew = defaultClockSkew
+	}
+
+	now := v.currentTime()
+	if ts.Created != "" {
+		created, err := time.Parse(time.RFC3339, strings.TrimSpace(ts.Created))
+		if err != nil {
+			return signatureError("invalid timestamp Created: %s", err)
+		}
+		if now.Add(skew).Before(created) {
+			return signatureError("timestamp created in the future, at %s", ts.Created)
+		}
+	}
+
+	if ts.Expires != "" {
+		expires, err := time.Parse(time.RFC3339, strings.TrimSpace(ts.Expires))
+		if err != nil {
+			return signatureError("invalid timestamp Expires: %s", err)
+		}
+		if !now.Add(-skew).Before(expires) {
+			return signatureError("timestamp expired at %s", ts.Expires)
+		}
+	}
+
+	return nil
+}
+
+// signer returns the certificate referenced by the KeyInfo of the signature, if trusted
+func (v *SignatureVerifier) signer(sec *wsseSecurity) (*x509.Certificate, error) {
+	ki := sec.Signature.KeyInfo
+	str := ki.TokenReference
+
+	var (
+		cert *x509.Certificate
+		der  string
+	)
+
+	switch {
+	case str.Reference.URI != "":
+		id := strings.TrimPrefix(str.Reference.URI, "#")
+		for _, t := range sec.Tokens {
+			if t.ID == id && t.ValueType == x509v3ValueType {
+				der = t.Value
+			}
+		}
+		if der == "" {
+			return nil, signatureError("security token %q not found", str.Reference.URI)
+		}
+	case str.KeyIdentifier.Value != "":
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(str.KeyIdentifier.Value))
+		if err != nil {
+			return nil, signatureError("invalid KeyIdentifier: %s", err)
+		}
+
+		for _, t := range v.Trusted {
+			switch str.KeyIdentifier.ValueType {
+			case thumbprintSHA1:
+				if sum := sha1.Sum(t.Raw); bytes.Equal(sum[:], value) {
+					cert = t
+				}
+			case x509SKIValueType:
+				if bytes.Equal(t.SubjectKeyId, value) {
+					cert = t
+				}
+			case x509v3ValueType:
+				if bytes.Equal(t.Raw, value) {
+					cert = t
+				}
+			}
+		}
+	case str.IssuerSerial.SerialNumber != "":
+		for _, t := range v.Trusted {
+			if t.SerialNumber.String() == strings.TrimSpace(str.IssuerSerial.SerialNumber) && t.Issuer.String() == strings.TrimSpace(str.IssuerSerial.IssuerName) {
+				cert = t
+			}
+		}
+	case ki.Certificate != "":
+		der = ki.Certificate
+	}
+
+	if der != "" {
+		raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(der), ""))
+		if err != nil {
+			return nil, signatureError("invalid certificate: %s", err)
+		}
+
+		if cert, err = x509.ParseCertificate(raw); err != nil {
+			return nil, signatureError("invalid certificate: %s", err)
+		}
+	}
+
+	if cert == nil {
+		return nil, signatureError("signer certificate not found or not trusted")
+	}
+
+	return cert, v.trust(cert)
+}
+
+// trust checks cert is one of the Trusted certificates or is issued by one of them
+func (v *SignatureVerifier) trust(cert *x509.Certificate) error {
+	roots := x509.NewCertPool()
+	for _, t := range v.Trusted {
+		if t.Equal(cert) {
+			return nil
+		}
+		roots.AddCert(t)
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: v.currentTime(),
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return signatureError("signer certificate not trusted: %s", err)
+	}
+
+	return nil
+}
+
+// scanSignedEnvelope collects the Ids of the elements of the envelope b, and the signature
+// and timestamp of the wsse:Security header at security
+func scanSignedEnvelope(b []byte, security elementOffsets) (*signedEnvelope, error) {
+	env := &signedEnvelope{ids: map[string]int{}, signedInfo: -1}
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var path []xml.Name
+	for {
+		offset := int(d.InputOffset())
+		t, err := d.Token()
+		if err == io.EOF {
+			return env, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name)
+
+			id := ""
+			for _, a := range t.Attr {
+				if (a.Name.Space == wsuNamespace && a.Name.Local == "Id") || (a.Name.Space == "" && (a.Name.Local == "Id" || a.Name.Local == "ID")) {
+					id = a.Value
+					env.ids[id]++
+				}
+			}
+
+			if len(path) == 2 && t.Name.Space == path[0].Space && t.Name.Local == "Body" {
+				env.bodyID = id
+			}
+
+			// descendants of the security header, its children are at depth 4
+			if offset < security.contentStart || offset >= security.contentEnd {
+				continue
+			}
+
+			switch {
+			case len(path) == 4 && t.Name.Space == wsuNamespace && t.Name.Local == "Timestamp":
+				env.timestamps++
+				env.timestampID = id
+			case len(path) == 4 && t.Name.Space == dsNamespace && t.Name.Local == "Signature":
+				env.signatures++
+			case len(path) == 5 && path[3].Space == dsNamespace && path[3].Local == "Signature" &&
+				t.Name.Space == dsNamespace && t.Name.Local == "SignedInfo":
+				env.signedInfos++
+				env.signedInfo = offset
+			}
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		}
+	}
+}
+
+// elementID returns the wsu:Id, Id or ID attribute of the raw start element s
+func elementID(s xml.StartElement, scope map[string]string) string {
+	for _, a := range s.Attr {
+		if a.Name.Space == "" && (a.Name.Local == "Id" || a.Name.Local == "ID") {
+			return a.Value
+		}
+		if a.Name.Space != "" && a.Name.Local == "Id" && scope[a.Name.Space] == wsuNamespace {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// canonicalizeElement returns the canonical form, following the transform t, of the
+// first element of b matched by apex
+func canonicalizeElement(b []byte, t dsTransform, apex apexFunc) ([]byte, error) {
+	var c canonicalizer
+	switch t.Algorithm {
+	case excC14NAlgorithm:
+		c = canonicalizer{exclusive: true, inclusive: map[string]bool{}}
+		for _, p := range strings.Fields(t.InclusiveNamespaces.PrefixList) {
+			if p == "#default" {
+				p = ""
+			}
+			c.inclusive[p] = true
+		}
+	case c14nAlgorithm:
+	default:
+		return nil, signatureError("unsupported canonicalization %q", t.Algorithm)
+	}
+
+	return c.canonicalize(b, apex)
+}
+
+func digestOf(algorithm string, b []byte) ([]byte, error) {
+	hash, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return nil, signatureError("unsupported digest algorithm %q", algorithm)
+	}
+
+	h := hash.New()
+	h.Write(b)
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,222 @@
+package gosoap
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedResponse is a response envelope signed by key, with the references to sign,
+// the certificate in a BinarySecurityToken and a timestamp valid from created to expires
+type signedResponse struct {
+	Body             string
+	References       []string
+	Created, Expires time.Time
+}
+
+func (s signedResponse) sign(t *testing.T, cert *x509.Certificate, key *rsa.PrivateKey) []byte {
+	envelope := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:wsu="` + wsuNamespace + `">` +
+		`<soap:Header><wsse:Security xmlns:wsse="` + wsseNamespace + `" soap:mustUnderstand="1">` +
+		`<wsu:Timestamp wsu:Id="TS-1"><wsu:Created>` + s.Created.UTC().Format(time.RFC3339) + `</wsu:Created>` +
+		`<wsu:Expires>` + s.Expires.UTC().Format(time.RFC3339) + `</wsu:Expires></wsu:Timestamp>` +
+		`<wsse:BinarySecurityToken EncodingType="` + wsseBase64Binary + `" ValueType="` + x509v3ValueType + `" wsu:Id="X509-1">` +
+		base64.StdEncoding.EncodeToString(cert.Raw) + `</wsse:BinarySecurityToken>{signature}</wsse:Security></soap:Header>` +
+		`<soap:Body wsu:Id="Body-1">` + s.Body + `</soap:Body></soap:Envelope>`
+
+	signedInfo := `<ds:SignedInfo><ds:CanonicalizationMethod Algorithm="` + excC14NAlgorithm + `"/>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>`
+	for _, id := range s.References {
+		canonical, err := canonicalizeElement([]byte(strings.Replace(envelope, "{signature}", "", 1)), dsTransform{Algorithm: excC14NAlgorithm},
+			func(e xml.StartElement, scope map[string]string, _ int) bool { return elementID(e, scope) == id })
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256(canonical)
+		signedInfo += `<ds:Reference URI="#` + id + `"><ds:Transforms><ds:Transform Algorithm="` + excC14NAlgorithm + `"/></ds:Transforms>` +
+			`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>` +
+			`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(sum[:]) + `</ds:DigestValue></ds:Reference>`
+	}
+	signedInfo += `</ds:SignedInfo>`
+
+	signature := `<ds:Signature xmlns:ds="` + dsNamespace + `">` + signedInfo + `<ds:SignatureValue>{value}</ds:SignatureValue>` +
+		`<ds:KeyInfo><wsse:SecurityTokenReference><wsse:Reference URI="#X509-1" ValueType="` + x509v3ValueType + `"/>` +
+		`</wsse:SecurityTokenReference></ds:KeyInfo></ds:Signature>`
+	envelope = strings.Replace(envelope, "{signature}", signature, 1)
+
+	canonical, err := canonicalizeElement([]byte(envelope), dsTransform{Algorithm: excC14NAlgorithm},
+		func(e xml.StartElement, scope map[string]string, _ int) bool { return e.Name.Local == "SignedInfo" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(canonical)
+	value, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []byte(strings.Replace(envelope, "{value}", base64.StdEncoding.EncodeToString(value), 1))
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	cert, key := newTestCertificate(t, "server")
+	body := fmt.Sprintf(`<GetOrderResponse xmlns="http://example.com/orders"><orderId>%s</orderId><status>NEW</status></GetOrderResponse>`, "1")
+	response := signedResponse{Body: body, References: []string{"TS-1", "Body-1"}, Created: time.Now(), Expires: time.Now().Add(5 * time.Minute)}
+
+	var format PayloadFormat
+	ts := newTestServer(t, "orders.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		b, err := format.encode(response.sign(t, cert, key))
+		if err != nil {
+			t.Fatalf("error not expected: %s", err)
+		}
+		w.Write(b)
+	})
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL+"?wsdl", WithSignatureVerifier(SignatureVerifier{Trusted: []*x509.Certificate{cert}}))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	res, err := c.Call("GetOrder", Params{"orderId": "1"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if res.SignerCertificate == nil || !res.SignerCertificate.Equal(cert) {
+		t.Errorf("signer certificate expected, got %v", res.SignerCertificate)
+	}
+	if !strings.Contains(string(res.Body), "<orderId>1</orderId>") {
+		t.Errorf("unexpected body %s", res.Body)
+	}
+
+	// signatures are verified on the envelope converted to UTF-8
+	format.Charset = "ISO-8859-1"
+	if _, err := c.Call("GetOrder", Params{"orderId": "1"}); err != nil {
+		t.Errorf("error not expected: %s", err)
+	}
+
+	response.References = []string{"TS-1"}
+	if _, err := c.Call("GetOrder", Params{"orderId": "1"}); err == nil || !strings.Contains(err.Error(), "body is not signed") {
+		t.Errorf("unsigned body error expected, got %v", err)
+	}
+}
+
+func TestSignatureVerifier_VerifyEnvelope(t *testing.T) {
+	cert, key := newTestCertificate(t, "server")
+	other, _ := newTestCertificate(t, "other")
+	now := time.Now()
+
+	valid := signedResponse{Body: `<a>1</a>`, References: []string{"TS-1", "Body-1"}, Created: now, Expires: now.Add(5 * time.Minute)}
+	b := valid.sign(t, cert, key)
+
+	// the SignedInfo of b in a decoy header, beside a forged signature reusing its SignatureValue
+	between := func(s, start, end string) string {
+		i := strings.Index(s, start)
+		return s[i : i+strings.Index(s[i:], end)+len(end)]
+	}
+	forged := valid
+	forged.Body = `<a>2</a>`
+	decoy := strings.Replace(string(forged.sign(t, cert, key)), "<soap:Header>", `<soap:Header><x:Security xmlns:x="urn:decoy">`+
+		`<ds:Signature xmlns:ds="`+dsNamespace+`">`+between(string(b), "<ds:SignedInfo>", "</ds:SignedInfo>")+`</ds:Signature></x:Security>`, 1)
+	decoy = strings.Replace(decoy, between(decoy, "<ds:SignatureValue>", "</ds:SignatureValue>"), between(string(b), "<ds:SignatureValue>", "</ds:SignatureValue>"), 1)
+
+	// a fresh unsigned timestamp after the signed one
+	replayed := strings.Replace(string(b), "</wsu:Timestamp>", "</wsu:Timestamp><wsu:Timestamp><wsu:Created>"+now.UTC().Format(time.RFC3339)+
+		"</wsu:Created><wsu:Expires>"+now.Add(time.Hour).UTC().Format(time.RFC3339)+"</wsu:Expires></wsu:Timestamp>", 1)
+
+	tests := []struct {
+		name     string
+		envelope []byte
+		trusted  *x509.Certificate
+		now      time.Time
+		wantErr  string
+	}{
+		{name: "valid", envelope: b, trusted: cert, now: now},
+		{name: "within clock skew", envelope: b, trusted: cert, now: now.Add(9 * time.Minute)},
+		{name: "expired", envelope: b, trusted: cert, now: now.Add(11 * time.Minute), wantErr: "timestamp expired"},
+		{name: "created in the future", envelope: b, trusted: cert, now: now.Add(-6 * time.Minute), wantErr: "created in the future"},
+		{name: "untrusted", envelope: b, trusted: other, now: now, wantErr: "not trusted"},
+		{name: "tampered body", envelope: []byte(strings.Replace(string(b), "<a>1</a>", "<a>2</a>", 1)), trusted: cert, now: now, wantErr: `digest of "Body-1"`},
+		{
+			name:     "insignificant whitespace",
+			envelope: []byte(strings.Replace(string(b), `URI="#TS-1"`, `URI="#TS-1" `, 1)),
+			trusted:  cert,
+			now:      now,
+		},
+		{
+			name:     "tampered signed info",
+			envelope: []byte(strings.Replace(string(b), "xmldsig-more#rsa-sha256", "xmldsig-more#rsa-sha512", 1)),
+			trusted:  cert,
+			now:      now,
+			wantErr:  "signature doesn't match",
+		},
+		{
+			name:     "wrapped body",
+			envelope: []byte(strings.Replace(string(b), "</soap:Header>", `<Wrapper wsu:Id="Body-1"/></soap:Header>`, 1)),
+			trusted:  cert,
+			now:      now,
+			wantErr:  `more than one element with Id "Body-1"`,
+		},
+		{
+			name:     "decoy security header",
+			envelope: []byte(decoy),
+			trusted:  cert,
+			now:      now,
+			wantErr:  "signature doesn't match",
+		},
+		{
+			name:     "repeated timestamp",
+			envelope: []byte(replayed),
+			trusted:  cert,
+			now:      now.Add(11 * time.Minute),
+			wantErr:  "more than one timestamp",
+		},
+		{
+			name:     "unsigned timestamp",
+			envelope: signedResponse{Body: `<a>1</a>`, References: []string{"Body-1"}, Created: now, Expires: now.Add(time.Minute)}.sign(t, cert, key),
+			trusted:  cert,
+			now:      now,
+			wantErr:  "timestamp is not signed",
+		},
+		{
+			name:     "unsigned",
+			envelope: []byte(fmt.Sprintf(getOrderResponse, "1", "")),
+			trusted:  cert,
+			now:      now,
+			wantErr:  "no security header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := tt.now
+			v := &SignatureVerifier{Trusted: []*x509.Certificate{tt.trusted}, now: func() time.Time { return at }}
+
+			signer, err := v.VerifyEnvelope(tt.envelope)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("error not expected: %s", err)
+				}
+				if !signer.Equal(cert) {
+					t.Errorf("unexpected signer %s", signer.Subject)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error containing %q expected, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
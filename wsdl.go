@@ -1,11 +1,13 @@
 package gosoap
 
 import (
+	"bytes"
 	"encoding/xml"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
 
 	"golang.org/x/net/html/charset"
 )
@@ -19,6 +21,9 @@ type wsdlDefinitions struct {
 	PortTypes       []*wsdlPortTypes `xml:"http://schemas.xmlsoap.org/wsdl/ portType"`
 	Services        []*wsdlService   `xml:"http://schemas.xmlsoap.org/wsdl/ service"`
 	Bindings        []*wsdlBinding   `xml:"http://schemas.xmlsoap.org/wsdl/ binding"`
+
+	// patterns caches the regexps of the xml schema patterns, see pattern
+	patterns sync.Map
 }
 
 type wsdlBinding struct {
@@ -30,6 +35,8 @@ type wsdlBinding struct {
 
 type soapBinding struct {
 	Transport string `xml:"transport,attr"`
+	// Version of SOAP, set for WSDL 2.0 bindings
+	Version string `xml:"-"`
 }
 
 type wsdlTypes struct {
@@ -49,6 +56,7 @@ type wsdlMessage struct {
 type wsdlMessagePart struct {
 	Name    string `xml:"name,attr"`
 	Element string `xml:"element,attr"`
+	Type    string `xml:"type,attr"`
 }
 
 type wsdlPortTypes struct {
@@ -106,6 +114,7 @@ type xsdSchema struct {
 	Imports            []*xsdImport      `xml:"http://www.w3.org/2001/XMLSchema import"`
 	Elements           []*xsdElement     `xml:"http://www.w3.org/2001/XMLSchema element"`
 	ComplexTypes       []*xsdComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleTypes        []*xsdSimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
 }
 
 type xsdImport struct {
@@ -115,6 +124,7 @@ type xsdImport struct {
 
 type xsdElement struct {
 	Name        string          `xml:"name,attr"`
+	Ref         string          `xml:"ref,attr"`
 	Nillable    bool            `xml:"nillable,attr"`
 	Type        string          `xml:"type,attr"`
 	MinOccurs   string          `xml:"minOccurs,attr"`
@@ -138,10 +148,14 @@ type xsdSequence struct {
 }
 
 type xsdRestriction struct {
-	Base         string           `xml:"base,attr"`
-	Pattern      *xsdPattern      `xml:"http://www.w3.org/2001/XMLSchema pattern"`
-	MinInclusive *xsdMinInclusive `xml:"http://www.w3.org/2001/XMLSchema minInclusive"`
-	MaxInclusive *xsdMaxInclusive `xml:"http://www.w3.org/2001/XMLSchema maxInclusive"`
+	Base         string            `xml:"base,attr"`
+	Patterns     []*xsdPattern     `xml:"http://www.w3.org/2001/XMLSchema pattern"`
+	MinInclusive *xsdMinInclusive  `xml:"http://www.w3.org/2001/XMLSchema minInclusive"`
+	MaxInclusive *xsdMaxInclusive  `xml:"http://www.w3.org/2001/XMLSchema maxInclusive"`
+	Enumerations []*xsdEnumeration `xml:"http://www.w3.org/2001/XMLSchema enumeration"`
+	Length       *xsdLength        `xml:"http://www.w3.org/2001/XMLSchema length"`
+	MinLength    *xsdMinLength     `xml:"http://www.w3.org/2001/XMLSchema minLength"`
+	MaxLength    *xsdMaxLength     `xml:"http://www.w3.org/2001/XMLSchema maxLength"`
 }
 
 type xsdPattern struct {
@@ -156,6 +170,22 @@ type xsdMaxInclusive struct {
 	Value string `xml:"value,attr"`
 }
 
+type xsdEnumeration struct {
+	Value string `xml:"value,attr"`
+}
+
+type xsdLength struct {
+	Value string `xml:"value,attr"`
+}
+
+type xsdMinLength struct {
+	Value string `xml:"value,attr"`
+}
+
+type xsdMaxLength struct {
+	Value string `xml:"value,attr"`
+}
+
 func (c *Client) getWsdlBody() (reader io.ReadCloser, err error) {
 	parse, err := url.Parse(c.wsdl)
 	if err != nil {
@@ -180,6 +210,10 @@ func (c *Client) getWsdlBody() (reader io.ReadCloser, err error) {
 		req.SetBasicAuth(c.Username, c.Password)
 	}
 
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -196,11 +230,50 @@ func (c *Client) getWsdlDefinitions() (wsdl *wsdlDefinitions, err error) {
 	}
 	defer reader.Close()
 
-	decoder := xml.NewDecoder(reader)
+	b, err := c.Limits.readAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Limits.check(b); err != nil {
+		return nil, err
+	}
+
+	return parseWsdl(b)
+}
+
+// parseWsdl decodes the WSDL 1.1 or 2.0 document b
+func parseWsdl(b []byte) (*wsdlDefinitions, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
 	decoder.CharsetReader = charset.NewReaderLabel
-	err = decoder.Decode(&wsdl)
 
-	return wsdl, err
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name.Space == wsdl20Namespace {
+			var d wsdl20Description
+			if err := decoder.DecodeElement(&d, &start); err != nil {
+				return nil, err
+			}
+
+			return d.definitions(), nil
+		}
+
+		var wsdl wsdlDefinitions
+		if err := decoder.DecodeElement(&wsdl, &start); err != nil {
+			return nil, err
+		}
+
+		return &wsdl, nil
+	}
 }
 
 // the SoapAction of an operation might differ from the action wsdl-operation name
@@ -208,10 +281,15 @@ func (c *Client) getWsdlDefinitions() (wsdl *wsdlDefinitions, err error) {
 func (wsdl *wsdlDefinitions) GetSoapActionFromWsdlOperation(operation string) string {
 	// in the future it would be nice to have Operations be map[string]*wsdlOperation,
 	// where the map key is the wsdlOperation name
-	if wsdl.Bindings[0] != nil {
-		for _, o := range wsdl.Bindings[0].Operations {
+	b := wsdl.portBinding()
+	if b == nil && len(wsdl.Bindings) > 0 {
+		b = wsdl.Bindings[0]
+	}
+
+	if b != nil {
+		for _, o := range b.Operations {
 			if o.Name == operation {
-				if o.SoapOperations[0] != nil {
+				if len(o.SoapOperations) > 0 && o.SoapOperations[0] != nil {
 					return o.SoapOperations[0].SoapAction
 				}
 			}
@@ -226,4 +304,9 @@ type Fault struct {
 	Code        string `xml:"faultcode"`
 	Description string `xml:"faultstring"`
 	Detail      string `xml:"detail"`
+	// DetailXML is the raw content of detail
+	DetailXML []byte `xml:"-"`
+
+	// entries of detail, decoded in the namespace scope of the envelope
+	entries []detailEntry
 }
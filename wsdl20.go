@@ -0,0 +1,218 @@
+package gosoap
+
+import (
+	"strings"
+)
+
+const (
+	wsdl20Namespace   = "http://www.w3.org/ns/wsdl"
+	wsdl20SOAPBinding = "http://www.w3.org/ns/wsdl/soap"
+)
+
+// wsdl20Description is a WSDL 2.0 document, mapped into the wsdlDefinitions of WSDL 1.1
+// so that both versions are navigated the same way
+type wsdl20Description struct {
+	TargetNamespace string             `xml:"targetNamespace,attr"`
+	Types           []*wsdlTypes       `xml:"http://www.w3.org/ns/wsdl types"`
+	Interfaces      []*wsdl20Interface `xml:"http://www.w3.org/ns/wsdl interface"`
+	Bindings        []*wsdl20Binding   `xml:"http://www.w3.org/ns/wsdl binding"`
+	Services        []*wsdl20Service   `xml:"http://www.w3.org/ns/wsdl service"`
+}
+
+type wsdl20Interface struct {
+	Name       string             `xml:"name,attr"`
+	Extends    string             `xml:"extends,attr"`
+	Faults     []*wsdl20Fault     `xml:"http://www.w3.org/ns/wsdl fault"`
+	Operations []*wsdl20Operation `xml:"http://www.w3.org/ns/wsdl operation"`
+}
+
+type wsdl20Fault struct {
+	Name    string `xml:"name,attr"`
+	Element string `xml:"element,attr"`
+}
+
+type wsdl20Operation struct {
+	Name      string              `xml:"name,attr"`
+	Inputs    []*wsdl20MessageRef `xml:"http://www.w3.org/ns/wsdl input"`
+	Outputs   []*wsdl20MessageRef `xml:"http://www.w3.org/ns/wsdl output"`
+	OutFaults []*wsdl20FaultRef   `xml:"http://www.w3.org/ns/wsdl outfault"`
+}
+
+type wsdl20MessageRef struct {
+	Element string `xml:"element,attr"`
+	Action  string `xml:"http://www.w3.org/2007/05/addressing/metadata Action,attr"`
+}
+
+type wsdl20FaultRef struct {
+	Ref    string `xml:"ref,attr"`
+	Action string `xml:"http://www.w3.org/2007/05/addressing/metadata Action,attr"`
+}
+
+type wsdl20Binding struct {
+	Name       string                    `xml:"name,attr"`
+	Interface  string                    `xml:"interface,attr"`
+	Type       string                    `xml:"type,attr"`
+	Version    string                    `xml:"http://www.w3.org/ns/wsdl/soap version,attr"`
+	Protocol   string                    `xml:"http://www.w3.org/ns/wsdl/soap protocol,attr"`
+	Operations []*wsdl20BindingOperation `xml:"http://www.w3.org/ns/wsdl operation"`
+}
+
+type wsdl20BindingOperation struct {
+	Ref    string `xml:"ref,attr"`
+	Action string `xml:"http://www.w3.org/ns/wsdl/soap action,attr"`
+}
+
+type wsdl20Service struct {
+	Name      string            `xml:"name,attr"`
+	Interface string            `xml:"interface,attr"`
+	Endpoints []*wsdl20Endpoint `xml:"http://www.w3.org/ns/wsdl endpoint"`
+}
+
+type wsdl20Endpoint struct {
+	Name    string `xml:"name,attr"`
+	Binding string `xml:"binding,attr"`
+	Address string `xml:"address,attr"`
+}
+
+// definitions maps the description into wsdlDefinitions: interfaces become port types,
+// with a message generated for each input, output and fault element, and endpoints
+// become ports. Only SOAP bindings are kept, endpoints of other bindings have no address
+func (d *wsdl20Description) definitions() *wsdlDefinitions {
+	wsdl := &wsdlDefinitions{
+		TargetNamespace: d.TargetNamespace,
+		Types:           d.Types,
+	}
+
+	for _, i := range d.Interfaces {
+		pt := &wsdlPortTypes{Name: i.Name}
+		for _, o := range d.interfaceOperations(i, map[string]bool{}) {
+			op := &wsdlOperation{Name: o.Name}
+			for _, in := range o.Inputs {
+				name := wsdl.addMessage(i.Name, o.Name, "input", in.Element)
+				op.Inputs = append(op.Inputs, &wsdlOperationInput{Message: name, WsawAction: in.Action})
+			}
+			for _, out := range o.Outputs {
+				name := wsdl.addMessage(i.Name, o.Name, "output", out.Element)
+				op.Outputs = append(op.Outputs, &wsdlOperationOutput{Message: name, WsawAction: out.Action})
+			}
+			for _, f := range o.OutFaults {
+				el := ""
+				if fault := d.fault(i, localName(f.Ref), map[string]bool{}); fault != nil {
+					el = fault.Element
+				}
+				name := wsdl.addMessage(i.Name, o.Name, localName(f.Ref), el)
+				op.Faults = append(op.Faults, &wsdlOperationFault{Name: localName(f.Ref), Message: name, WsawAction: f.Action})
+			}
+
+			pt.Operations = append(pt.Operations, op)
+		}
+
+		wsdl.PortTypes = append(wsdl.PortTypes, pt)
+	}
+
+	soap := map[string]bool{}
+	for _, b := range d.Bindings {
+		if b.Type != wsdl20SOAPBinding {
+			continue
+		}
+		soap[b.Name] = true
+
+		// SOAP 1.2 is the default version of WSDL 2.0 bindings
+		version := b.Version
+		if version == "" {
+			version = "1.2"
+		}
+
+		binding := &wsdlBinding{
+			Name:         b.Name,
+			Type:         b.Interface,
+			SoapBindings: []*soapBinding{{Transport: b.Protocol, Version: version}},
+		}
+		for _, o := range b.Operations {
+			binding.Operations = append(binding.Operations, &wsdlOperation{
+				Name:           localName(o.Ref),
+				SoapOperations: []*soapOperation{{SoapAction: o.Action}},
+			})
+		}
+
+		wsdl.Bindings = append(wsdl.Bindings, binding)
+	}
+
+	for _, s := range d.Services {
+		service := &wsdlService{Name: s.Name}
+		for _, e := range s.Endpoints {
+			port := &wsdlPort{Name: e.Name, Binding: e.Binding}
+			if soap[localName(e.Binding)] && e.Address != "" {
+				port.SoapAddresses = []*soapAddress{{Location: e.Address}}
+			}
+			service.Ports = append(service.Ports, port)
+		}
+
+		wsdl.Services = append(wsdl.Services, service)
+	}
+
+	return wsdl
+}
+
+// interfaceOperations returns the operations of i and of the interfaces it extends
+func (d *wsdl20Description) interfaceOperations(i *wsdl20Interface, seen map[string]bool) []*wsdl20Operation {
+	if seen[i.Name] {
+		return nil
+	}
+	seen[i.Name] = true
+
+	ops := i.Operations
+	for _, e := range strings.Fields(i.Extends) {
+		if parent := d.iface(e); parent != nil {
+			ops = append(ops, d.interfaceOperations(parent, seen)...)
+		}
+	}
+
+	return ops
+}
+
+// fault returns the fault name declared by i or by the interfaces it extends
+func (d *wsdl20Description) fault(i *wsdl20Interface, name string, seen map[string]bool) *wsdl20Fault {
+	if seen[i.Name] {
+		return nil
+	}
+	seen[i.Name] = true
+
+	for _, f := range i.Faults {
+		if f.Name == name {
+			return f
+		}
+	}
+
+	for _, e := range strings.Fields(i.Extends) {
+		if parent := d.iface(e); parent != nil {
+			if f := d.fault(parent, name, seen); f != nil {
+				return f
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *wsdl20Description) iface(name string) *wsdl20Interface {
+	for _, i := range d.Interfaces {
+		if i.Name == localName(name) {
+			return i
+		}
+	}
+
+	return nil
+}
+
+// addMessage adds the message of an operation input, output or fault carrying element,
+// and returns its name. The #any, #none and #other tokens give messages without parts
+func (wsdl *wsdlDefinitions) addMessage(iface, operation, label, element string) string {
+	m := &wsdlMessage{Name: iface + "#" + operation + "#" + label}
+	if element != "" && !strings.HasPrefix(element, "#") {
+		m.Parts = []*wsdlMessagePart{{Name: "parameters", Element: element}}
+	}
+
+	wsdl.Messages = append(wsdl.Messages, m)
+	return m.Name
+}
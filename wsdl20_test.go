@@ -0,0 +1,142 @@
+package gosoap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDefinitions_WSDL20(t *testing.T) {
+	d := loadTestDefinitions(t, "orders20.wsdl")
+
+	if names := d.OperationNames(); len(names) != 2 || names[0] != "CreateOrder" || names[1] != "GetOrder" {
+		t.Errorf("operations of the interface and the extended one expected, got %v", names)
+	}
+
+	s, err := d.InputSchema("CreateOrder")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if s.Name != "CreateOrder" || s.Namespace != "http://example.com/orders" || s.Child("item") == nil {
+		t.Errorf("unexpected schema: %+v", s)
+	}
+
+	if out, err := d.OutputSchema("GetOrder"); err != nil || out.Name != "GetOrderResponse" {
+		t.Errorf("unexpected output schema: %+v, %v", out, err)
+	}
+
+	// the SOAP 1.1 endpoint is preferred to the SOAP 1.2 and http ones
+	if e, err := d.Endpoint(); err != nil || e != "http://localhost/orders" {
+		t.Errorf("unexpected endpoint %q: %v", e, err)
+	}
+
+	if a := d.GetSoapActionFromWsdlOperation("GetOrder"); a != "http://example.com/orders/GetOrder" {
+		t.Errorf("unexpected action %q", a)
+	}
+
+	op := d.operation("CreateOrder")
+	if op.Inputs[0].WsawAction != "http://example.com/orders/CreateOrderRequest" {
+		t.Errorf("unexpected input action %q", op.Inputs[0].WsawAction)
+	}
+	if len(op.Faults) != 1 || op.Faults[0].Name != "ValidationFault" {
+		t.Fatalf("unexpected faults %+v", op.Faults)
+	}
+	if m := d.message(op.Faults[0].Message); m == nil || m.Parts[0].Element != "tns:ValidationFault" {
+		t.Errorf("unexpected fault message %+v", m)
+	}
+
+	if fault := d.operation("GetOrder").Faults; len(fault) != 1 || d.message(fault[0].Message).Parts[0].Element != "tns:NotFoundFault" {
+		t.Errorf("fault of the extended interface expected, got %+v", fault)
+	}
+}
+
+func TestClient_WSDL20(t *testing.T) {
+	ts := newTestServer(t, "orders20.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/soap" || r.Header.Get("SOAPAction") != "http://example.com/orders/GetOrder" {
+			http.Error(w, fmt.Sprintf("unexpected request %s %s", r.URL.Path, r.Header.Get("SOAPAction")), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprintf(w, getOrderResponse, "1", "")
+	})
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	res, err := c.Call("GetOrder", Params{"orderId": "1"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	if !strings.Contains(string(res.Body), "<orderId>1</orderId>") {
+		t.Errorf("unexpected body %s", res.Body)
+	}
+	if !strings.Contains(string(res.Payload), `<GetOrder xmlns="http://example.com/orders">`) {
+		t.Errorf("unexpected request %s", res.Payload)
+	}
+}
+
+func TestClient_WSDL20SOAP12(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/orders20.wsdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without wsoap:version the SOAP binding is a SOAP 1.2 one too
+	data = bytes.Replace(data, []byte(` wsoap:version="1.1"`), nil, 1)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write(bytes.ReplaceAll(data, []byte("http://localhost/orders"), []byte(ts.URL)))
+			return
+		}
+
+		ct := r.Header.Get("Content-Type")
+		if r.URL.Path != "/soap12" || ct != `application/soap+xml;charset=UTF-8;action="urn:CreateOrder12"` || r.Header["Soapaction"] != nil {
+			http.Error(w, fmt.Sprintf("unexpected request %s %s %v", r.URL.Path, ct, r.Header["Soapaction"]), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/soap+xml")
+		fmt.Fprint(w, `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:tns="http://example.com/orders">
+	<env:Body>
+		<env:Fault>
+			<env:Code><env:Value>env:Sender</env:Value></env:Code>
+			<env:Reason><env:Text xml:lang="en">invalid order</env:Text></env:Reason>
+			<env:Detail><tns:ValidationFault><tns:field>item</tns:field><tns:message>missing</tns:message></tns:ValidationFault></env:Detail>
+		</env:Fault>
+	</env:Body>
+</env:Envelope>`)
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL + "?wsdl")
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	res, err := c.Call("CreateOrder", Params{"customerId": "1"})
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	var fault *FaultError
+	if err := res.Unmarshal(&struct{}{}); !errors.As(err, &fault) {
+		t.Fatalf("fault expected, got %v", res.Unmarshal(&struct{}{}))
+	}
+	if fault.Code != "env:Sender" || fault.Description != "invalid order" || !strings.Contains(string(fault.DetailXML), "<tns:field>item</tns:field>") {
+		t.Errorf("unexpected fault %+v", fault)
+	}
+
+	if !strings.Contains(string(res.Payload), `xmlns:soap="http://www.w3.org/2003/05/soap-envelope"`) {
+		t.Errorf("SOAP 1.2 envelope expected, got %s", res.Payload)
+	}
+}
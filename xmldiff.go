@@ -0,0 +1,180 @@
+package gosoap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Difference is a difference between two xml documents found by DiffXML
+type Difference struct {
+	Path    string
+	Message string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s", d.Path, d.Message)
+}
+
+// DiffXML compares the xml documents a and b independently of namespace prefixes,
+// namespace declarations, attribute order and whitespace between elements.
+// Text is compared with surrounding whitespace trimmed. No differences means
+// the documents are equivalent
+func DiffXML(a, b []byte) ([]Difference, error) {
+	na, err := parseDiffNode(a)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := parseDiffNode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []Difference
+	if na == nil || nb == nil {
+		if na != nb {
+			diffs = append(diffs, Difference{Path: "/", Message: "document element missing"})
+		}
+		return diffs, nil
+	}
+
+	diffNodes(na, nb, "/"+na.name.Local, &diffs)
+
+	return diffs, nil
+}
+
+// diffNode is an element of a compared document, with resolved namespaces
+type diffNode struct {
+	name     xml.Name
+	attrs    map[xml.Name]string
+	text     string
+	children []*diffNode
+}
+
+func parseDiffNode(b []byte) (*diffNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+
+	var stack []*diffNode
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &diffNode{name: t.Name, attrs: map[xml.Name]string{}}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+					continue
+				}
+				n.attrs[a.Name] = a.Value
+			}
+
+			if len(stack) > 0 {
+				p := stack[len(stack)-1]
+				p.children = append(p.children, n)
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			n.text = strings.TrimSpace(n.text)
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return n, nil
+			}
+		}
+	}
+}
+
+func diffNodes(a, b *diffNode, path string, diffs *[]Difference) {
+	add := func(path, format string, args ...interface{}) {
+		*diffs = append(*diffs, Difference{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if a.name != b.name {
+		add(path, "element %s, got %s", expandedName(a.name), expandedName(b.name))
+		return
+	}
+
+	names := make([]xml.Name, 0, len(a.attrs)+len(b.attrs))
+	for n := range a.attrs {
+		names = append(names, n)
+	}
+	for n := range b.attrs {
+		if _, ok := a.attrs[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return expandedName(names[i]) < expandedName(names[j])
+	})
+
+	for _, n := range names {
+		va, inA := a.attrs[n]
+		vb, inB := b.attrs[n]
+		switch {
+		case !inB:
+			add(path, "attribute %s missing", expandedName(n))
+		case !inA:
+			add(path, "unexpected attribute %s", expandedName(n))
+		case va != vb:
+			add(path, "attribute %s is %q, got %q", expandedName(n), va, vb)
+		}
+	}
+
+	if a.text != b.text {
+		add(path, "text %q, got %q", a.text, b.text)
+	}
+
+	pa, pb := childPaths(a, path), childPaths(b, path)
+	for i := 0; i < len(a.children) || i < len(b.children); i++ {
+		switch {
+		case i >= len(b.children):
+			add(pa[i], "element missing")
+		case i >= len(a.children):
+			add(pb[i], "unexpected element")
+		default:
+			diffNodes(a.children[i], b.children[i], pa[i], diffs)
+		}
+	}
+}
+
+// childPaths returns the paths of the children of n, indexed when names are repeated
+func childPaths(n *diffNode, path string) []string {
+	counts := map[xml.Name]int{}
+	for _, c := range n.children {
+		counts[c.name]++
+	}
+
+	seen := map[xml.Name]int{}
+	paths := make([]string, len(n.children))
+	for i, c := range n.children {
+		seen[c.name]++
+		paths[i] = path + "/" + c.name.Local
+		if counts[c.name] > 1 {
+			paths[i] = fmt.Sprintf("%s[%d]", paths[i], seen[c.name])
+		}
+	}
+
+	return paths
+}
+
+func expandedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+
+	return "{" + n.Space + "}" + n.Local
+}
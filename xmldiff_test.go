@@ -0,0 +1,61 @@
+package gosoap
+
+import (
+	"testing"
+)
+
+func TestDiffXML(t *testing.T) {
+	a := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+		<soap:Body><Order xmlns="urn:o" id="1" kind="web"><item>a</item><item>b</item></Order></soap:Body>
+	</soap:Envelope>`
+
+	same := `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Body>
+		<o:Order xmlns:o="urn:o" kind="web" id="1">
+			<o:item> a </o:item>
+			<o:item>b</o:item>
+		</o:Order>
+	</env:Body></env:Envelope>`
+
+	diffs, err := DiffXML([]byte(a), []byte(same))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("documents must be equivalent: %v", diffs)
+	}
+
+	other := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+		<soap:Body><Order xmlns="urn:x" id="2"><item>a</item></Order><extra/></soap:Body>
+	</soap:Envelope>`
+
+	diffs, err = DiffXML([]byte(a), []byte(other))
+	if err != nil {
+		t.Fatalf("error not expected: %s", err)
+	}
+
+	want := []string{
+		"/Envelope/Body/Order: element {urn:o}Order, got {urn:x}Order",
+		"/Envelope/Body/extra: unexpected element",
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %v, want %v", diffs, want)
+	}
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("got %q, want %q", d, want[i])
+		}
+	}
+
+	diffs, _ = DiffXML([]byte(`<a x="1"><b>1</b><b>2</b></a>`), []byte(`<a y="1"><b>1</b><b>3</b></a>`))
+	want = []string{
+		`/a: attribute x missing`,
+		`/a: unexpected attribute y`,
+		`/a/b[2]: text "2", got "3"`,
+	}
+	for i, d := range diffs {
+		if i >= len(want) || d.String() != want[i] {
+			t.Errorf("got %v, want %v", diffs, want)
+			break
+		}
+	}
+}